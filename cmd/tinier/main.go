@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,16 +15,10 @@ import (
 	"github.com/qdm12/gosettings/reader/sources/env"
 	"github.com/qdm12/gosettings/reader/sources/flag"
 	"github.com/qdm12/log"
-	"github.com/qdm12/tinier/internal/cmd"
 	"github.com/qdm12/tinier/internal/config"
-	"github.com/qdm12/tinier/internal/ffmpeg"
-	"github.com/qdm12/tinier/internal/filetime"
 	"github.com/qdm12/tinier/internal/models"
-	"github.com/qdm12/tinier/internal/path"
-	"github.com/qdm12/tinier/internal/semver"
-	"github.com/qdm12/tinier/internal/size"
-	"github.com/qdm12/tinier/internal/spinner"
-	"github.com/qdm12/tinier/internal/stats"
+	"github.com/qdm12/tinier/internal/server"
+	"github.com/qdm12/tinier/pkg/tinier"
 )
 
 //nolint:gochecknoglobals
@@ -45,10 +38,12 @@ func main() {
 		Date:    date,
 	}
 
-	flag := flag.New(os.Args)
+	command, args := splitCommand(os.Args)
+	flag := flag.New(args)
+	const envKeyPrefix = "TINIER_"
 	env := env.New(env.Settings{
-		Environ:   os.Environ(),
-		KeyPrefix: "TINIER_",
+		Environ:   trimEnvironPrefix(os.Environ(), envKeyPrefix),
+		KeyPrefix: envKeyPrefix,
 	})
 	reader := reader.New(reader.Settings{
 		Sources: []reader.Source{flag, env},
@@ -56,7 +51,7 @@ func main() {
 
 	errorCh := make(chan error)
 	go func() {
-		errorCh <- _main(ctx, buildInfo, reader, os.Stdout, os.Stdin,
+		errorCh <- _main(ctx, buildInfo, command, reader, os.Stdout, os.Stderr, os.Stdin,
 			http.DefaultClient)
 	}()
 
@@ -66,7 +61,7 @@ func main() {
 		if err == nil { // expected exit
 			os.Exit(0)
 		}
-		fmt.Println("Fatal error:", err)
+		fmt.Fprintln(os.Stderr, "Fatal error:", err)
 		os.Exit(1)
 	case <-ctx.Done():
 		stop()
@@ -80,421 +75,140 @@ func main() {
 			<-timer.C
 		}
 	case <-timer.C:
-		fmt.Println("Shutdown timed out")
+		fmt.Fprintln(os.Stderr, "Shutdown timed out")
 	}
 
 	os.Exit(1)
 }
 
-type HTTPClient interface {
-	Do(request *http.Request) (response *http.Response, err error)
-}
-
-const (
-	fileAlreadyExists = "✔️  file already exists"
-)
-
-//nolint:wrapcheck
-func _main(ctx context.Context, buildInfo models.BuildInfo,
-	reader *reader.Reader, stdout io.Writer, _ io.Reader,
-	httpClient HTTPClient) error {
-	versionMessage := fmt.Sprintf("🤖 Version %s (commit %s built on %s)",
-		buildInfo.Version, buildInfo.Commit, buildInfo.Date)
-	fmt.Fprintln(stdout, versionMessage)
-
-	var settings config.Settings
-	err := settings.Read(reader)
-	if err != nil {
-		return fmt.Errorf("reading settings: %w", err)
-	}
-	settings.SetDefaults()
-	err = settings.Validate()
-	if err != nil {
-		return fmt.Errorf("invalid settings: %w", err)
-	}
-
-	fmt.Fprintln(stdout, settings.String())
-
-	// Log level parse error checked in settings validation.
-	logLevel, _ := log.ParseLevel(settings.Log.Level)
-	logger := log.New(log.SetLevel(logLevel))
+const commandServe = "serve"
 
-	cmd := cmd.New()
-
-	minVersion := semver.MustParse(settings.FfmpegMinVersion)
-
-	ffmpegPath, err := ffmpeg.SetupFFMPEG(ctx, minVersion,
-		*settings.FfmpegPath, cmd, httpClient, stdout)
-	if err != nil {
-		fmt.Fprintln(stdout, "❌")
-		return fmt.Errorf("failed to setup ffmpeg: %w", err)
-	}
-
-	ffmpeg := ffmpeg.New(cmd, ffmpegPath, minVersion, logger)
-
-	fmt.Fprintf(stdout, "📁 Reading input directory %s... ", settings.InputDirPath)
-	imagePaths, audioPaths, videoPaths, otherPaths, err := path.Walk(
-		settings.InputDirPath, settings.Image.Extensions,
-		settings.Audio.Extensions, settings.Video.Extensions)
-	if err != nil {
-		fmt.Fprintln(stdout, "❌")
-		return err
-	}
-
-	fmt.Fprintf(stdout,
-		"%d image(s), %d audio file(s) and %d video(s) found\n",
-		len(imagePaths), len(audioPaths), len(videoPaths))
-
-	fmt.Fprintf(stdout, "📁 Creating output directory %s if needed... ", settings.OutputDirPath)
-	const dirPerms fs.FileMode = 0700
-	err = os.MkdirAll(settings.OutputDirPath, dirPerms)
-	if err != nil {
-		fmt.Fprintln(stdout, "❌")
-		return err
-	}
-	fmt.Fprintln(stdout, "✔️")
-
-	stats := stats.New()
-	defer stats.Finish(stdout)
-
-	doOthers(ctx, settings, otherPaths, stats, stdout)
-	if err = ctx.Err(); err != nil {
-		return err
-	}
-
-	doAudios(ctx, settings, audioPaths, ffmpeg, stats, stdout)
-	if err = ctx.Err(); err != nil {
-		return err
-	}
-
-	doImages(ctx, settings, imagePaths, ffmpeg, stats, stdout)
-	if err = ctx.Err(); err != nil {
-		return err
-	}
-
-	doVideos(ctx, settings, videoPaths, ffmpeg, stats, stdout)
-	return ctx.Err()
-}
-
-func doOthers(ctx context.Context, settings config.Settings,
-	inputPaths []string, stats *stats.Stats, w io.Writer) {
-	for _, inputPath := range inputPaths {
-		fmt.Fprintf(w, "🗄️  Copying %s ... ", inputPath)
-
-		outcome, err := doOther(settings, inputPath)
-		if err != nil {
-			stats.Failures++
-			outcome += warnSignErr(err)
-		}
-		fmt.Fprintln(w, outcome)
-		if ctx.Err() != nil { // program stopped by user
-			return
+// splitCommand returns the command found in the program arguments
+// given, such as `serve`, and the arguments without it, such that
+// flags can be given before or after the command. The command
+// returned is empty if no command is found.
+func splitCommand(args []string) (command string, flagArgs []string) {
+	flagArgs = make([]string, 0, len(args))
+	for i, arg := range args {
+		if i > 0 && command == "" && arg == commandServe {
+			command = arg
+			continue
 		}
+		flagArgs = append(flagArgs, arg)
 	}
+	return command, flagArgs
 }
 
-func doImages(ctx context.Context, settings config.Settings,
-	inputPaths []string, ffmpeg *ffmpeg.FFMPEG, stats *stats.Stats,
-	w io.Writer) {
-	if *settings.Image.Skip {
-		fmt.Fprintln(w, "⚠️ Skipping image files")
-		return
-	}
-	for _, inputPath := range inputPaths {
-		fmt.Fprintf(w, "🗜️  Tinying %s ... ", inputPath)
-		outcome, err := doImage(ctx, settings, inputPath, ffmpeg, stats)
-		if err != nil {
-			stats.Failures++
-			outcome += warnSignErr(err)
-		}
-		fmt.Fprintln(w, outcome)
-		if ctx.Err() != nil { // program stopped by user
-			return
-		}
-	}
-}
-
-func doAudios(ctx context.Context, settings config.Settings,
-	inputPaths []string, ffmpeg *ffmpeg.FFMPEG, stats *stats.Stats,
-	w io.Writer) {
-	if *settings.Audio.Skip {
-		fmt.Fprintln(w, "⚠️ Skipping audio files")
-		return
-	}
-	for _, inputPath := range inputPaths {
-		fmt.Fprintf(w, "🗜️  Tinying %s ... ", inputPath)
-		outcome, err := doAudio(ctx, settings, inputPath, ffmpeg, stats)
-		if err != nil {
-			stats.Failures++
-			outcome += warnSignErr(err)
-		}
-		fmt.Fprintln(w, outcome)
-		if ctx.Err() != nil { // program stopped by user
-			return
+// trimEnvironPrefix returns the environment variables starting with
+// the prefix given, with the prefix removed, since the env source
+// adds its key prefix to the environment variable keys it is given.
+func trimEnvironPrefix(environ []string, prefix string) (trimmed []string) {
+	trimmed = make([]string, 0, len(environ))
+	for _, keyValue := range environ {
+		if strings.HasPrefix(keyValue, prefix) {
+			trimmed = append(trimmed, strings.TrimPrefix(keyValue, prefix))
 		}
 	}
+	return trimmed
 }
 
-func doVideos(ctx context.Context, settings config.Settings,
-	inputPaths []string, ffmpeg *ffmpeg.FFMPEG, stats *stats.Stats,
-	w io.Writer) {
-	if *settings.Video.Skip {
-		fmt.Fprintln(w, "⚠️ Skipping video files")
-		return
-	}
-	for _, inputPath := range inputPaths {
-		outcome, err := doVideo(ctx, settings, inputPath, ffmpeg, stats, w)
-		if err != nil {
-			stats.Failures++
-			outcome += "  ⚠️  " + err.Error()
-		}
-		fmt.Fprintln(w, outcome)
-		if ctx.Err() != nil { // program stopped by user
-			return
-		}
-	}
-}
-
-func doOther(settings config.Settings, inputPath string) (
-	outcome string, err error) {
-	_, outputPath := path.InputToOutput(inputPath, settings.OutputDirPath, "")
-
-	if !*settings.OverrideOutput {
-		exist, err := path.DoesFileExist(outputPath)
-		if err != nil {
-			return "", err
-		} else if exist {
-			return fileAlreadyExists, nil
-		}
-	}
-
-	outputDir := filepath.Dir(outputPath)
-	const dirPerms os.FileMode = 0700
-	err = os.MkdirAll(outputDir, dirPerms)
-	if err != nil {
-		return "", fmt.Errorf("cannot create parent output directory: %w", err)
-	}
-
-	srcFile, err := os.Open(inputPath)
-	if err != nil {
-		return "", fmt.Errorf("cannot open input file: %w", err)
-	}
-
-	const filePerm os.FileMode = 0600
-	dstFile, err := os.OpenFile(outputPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, filePerm)
-	if err != nil {
-		_ = srcFile.Close()
-		return "", fmt.Errorf("cannot open output file: %w", err)
-	}
-
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		_ = srcFile.Close()
-		_ = dstFile.Close()
-		return "", fmt.Errorf("cannot copy: %w", err)
-	}
-
-	err = filetime.Copy(outputPath, inputPath)
+//nolint:wrapcheck
+func _main(ctx context.Context, buildInfo models.BuildInfo, command string,
+	reader *reader.Reader, stdout, stderr io.Writer, _ io.Reader,
+	httpClient tinier.HTTPClient) (err error) {
+	var settings config.Settings
+	err = settings.Read(reader)
 	if err != nil {
-		_ = os.Remove(outputPath) // clean up
-		return "", err
+		return fmt.Errorf("reading settings: %w", err)
 	}
 
-	return "✔️", nil
-}
-
-func doImage(ctx context.Context, settings config.Settings,
-	inputPath string, ffmpeg *ffmpeg.FFMPEG, stats *stats.Stats) (
-	outcome string, err error) {
-	_, outputPath := path.InputToOutput(inputPath,
-		settings.OutputDirPath, settings.Image.OutputExtension)
-
-	if !*settings.OverrideOutput {
-		exist, err := path.DoesFileExist(outputPath)
+	settingsFilePath := config.ReadFilePath(reader)
+	if settingsFilePath != "" {
+		fileSettings, err := config.ReadFile(settingsFilePath)
 		if err != nil {
-			return "", err
-		} else if exist {
-			return fileAlreadyExists, nil
+			return fmt.Errorf("reading settings file: %w", err)
 		}
+		// Flags and environment variables take precedence
+		// over the settings file.
+		fileSettings.OverrideWith(settings)
+		settings = fileSettings
 	}
 
-	outputDir := filepath.Dir(outputPath)
-	const dirPerms os.FileMode = 0700
-	err = os.MkdirAll(outputDir, dirPerms)
+	// The processor is given the settings before they are prepared,
+	// to merge directory settings files onto them before their
+	// defaults are set.
+	rawSettings := settings
+	err = tinier.PrepareSettings(&settings)
 	if err != nil {
-		return "", fmt.Errorf("cannot create parent output directory: %w", err)
-	}
-
-	err = ffmpeg.TinyImage(ctx, inputPath, outputPath,
-		settings.Image.Codec, settings.Image.Scale,
-		settings.Image.CRF, settings.Image.QScale)
-	if err != nil {
-		_ = os.Remove(outputPath) // clean up
-		return "", err
+		return err
 	}
 
-	outcome, err = sizeCheck(inputPath, outputPath, stats)
-	if err != nil {
-		_ = os.Remove(outputPath) // clean up
-		return "", err
+	// With the ndjson output format, stdout is reserved to events,
+	// so the human readable output is discarded and logs are
+	// written to stderr.
+	var sink tinier.EventSink
+	logWriter := stdout
+	if settings.OutputFormat == config.OutputFormatNDJSON {
+		sink = tinier.NewNDJSONSink(stdout)
+		stdout = io.Discard
+		logWriter = stderr
 	}
 
-	err = filetime.Copy(outputPath, inputPath)
-	if err != nil {
-		_ = os.Remove(outputPath) // clean up
-		return outcome, err
+	versionMessage := fmt.Sprintf("🤖 Version %s (commit %s built on %s)",
+		buildInfo.Version, buildInfo.Commit, buildInfo.Date)
+	fmt.Fprintln(stdout, versionMessage)
+	if settingsFilePath != "" {
+		fmt.Fprintf(stdout, "📄 Using settings file %s\n", settingsFilePath)
 	}
+	fmt.Fprintln(stdout, settings.String())
 
-	return outcome, nil
-}
-
-func doAudio(ctx context.Context, settings config.Settings,
-	inputPath string, ffmpeg *ffmpeg.FFMPEG, stats *stats.Stats) (
-	outcome string, err error) {
-	outputTempPath, outputPath := path.InputToOutput(inputPath,
-		settings.OutputDirPath, settings.Audio.OutputExtension)
+	// Log level parse error checked in settings validation.
+	logLevel, _ := log.ParseLevel(settings.Log.Level)
+	logger := log.New(log.SetLevel(logLevel), log.SetWriters(logWriter))
 
-	outputFileExists, err := path.DoesFileExist(outputPath)
-	if err != nil {
-		return "", err
+	if command == commandServe {
+		return serve(ctx, settings, rawSettings, httpClient, logger, sink, stdout)
 	}
 
-	if outputFileExists {
-		if !*settings.OverrideOutput {
-			return fileAlreadyExists, nil
-		}
-
-		err = os.Remove(outputPath)
-		if err != nil {
-			return "", fmt.Errorf("removing existing output file: %w", err)
-		}
+	if *settings.DryRun {
+		return tinier.DryRun(rawSettings, logger, stdout)
 	}
 
-	outputDir := filepath.Dir(outputPath)
-	const dirPerms os.FileMode = 0700
-	err = os.MkdirAll(outputDir, dirPerms)
+	processor, err := tinier.New(ctx, rawSettings, httpClient, logger, sink, stdout)
 	if err != nil {
-		return "", fmt.Errorf("cannot create parent output directory: %w", err)
+		return err
 	}
-
 	defer func() {
-		_ = os.Remove(outputTempPath) // clean up
+		_ = processor.Close()
 	}()
-	err = ffmpeg.TinyAudio(ctx, inputPath, outputTempPath,
-		settings.Audio.Codec, *settings.Audio.QScale, *settings.Audio.BitRate)
-	if err != nil {
-		return "", err
-	}
-
-	outcome, err = sizeCheck(inputPath, outputTempPath, stats)
-	if err != nil {
-		return "", err
-	}
-
-	err = filetime.Copy(outputTempPath, inputPath)
-	if err != nil {
-		return outcome, err
-	}
 
-	err = os.Rename(outputTempPath, outputPath)
-	if err != nil {
-		return outcome, fmt.Errorf("renaming temp output file to final output file: %w", err)
-	}
-
-	return outcome, nil
+	return processor.Run(ctx)
 }
 
-func doVideo(ctx context.Context, settings config.Settings,
-	inputPath string, ffmpeg *ffmpeg.FFMPEG, stats *stats.Stats,
-	w io.Writer) (outcome string, err error) {
-	tempOutputPath, outputPath := path.InputToOutput(inputPath,
-		settings.OutputDirPath, settings.Video.OutputExtension)
-
-	line := fmt.Sprintf("🗜️  Tinying %s ...", inputPath)
-	fmt.Fprint(w, line)
-
-	outputFileExists, err := path.DoesFileExist(outputPath)
-	if err != nil {
-		return "", err
-	}
-
-	if outputFileExists {
-		if !*settings.OverrideOutput {
-			return fileAlreadyExists, nil
-		}
-
-		err = os.Remove(outputPath)
-		if err != nil {
-			return "", fmt.Errorf("removing existing output file: %w", err)
-		}
-	}
+// serve runs the HTTP API server until the context is canceled.
+// The raw settings are the settings before they are prepared.
+func serve(ctx context.Context, settings, rawSettings config.Settings,
+	httpClient tinier.HTTPClient, logger *log.Logger, sink tinier.EventSink,
+	stdout io.Writer) (err error) {
+	fmt.Fprintln(stdout, settings.Server.String())
 
-	outputDir := filepath.Dir(outputPath)
-	const dirPerms os.FileMode = 0700
-	err = os.MkdirAll(outputDir, dirPerms)
+	uploadDirPath, err := os.MkdirTemp("", "tinier-uploads-")
 	if err != nil {
-		return "", fmt.Errorf("cannot create parent output directory: %w", err)
+		return fmt.Errorf("creating upload directory: %w", err)
 	}
-
-	spinner.ClearLine(w)
-	spinner := spinner.New(w, line, []rune{'⌛', '⏳', '🔥'})
-	spinner.Start()
-
 	defer func() {
-		_ = os.Remove(tempOutputPath) // clean up
+		_ = os.RemoveAll(uploadDirPath)
 	}()
-	err = ffmpeg.TinyVideo(ctx, inputPath, tempOutputPath,
-		settings.Video.Scale, settings.Video.Preset, settings.Video.Codec,
-		*settings.Video.Crf)
-	spinner.Stop()
-	if err != nil {
-		return "", err
-	}
-
-	outcome, err = sizeCheck(inputPath, tempOutputPath, stats)
-	if err != nil {
-		return "", err
-	}
 
-	err = filetime.Copy(tempOutputPath, inputPath)
+	processor, err := tinier.New(ctx, rawSettings, httpClient, logger, sink, stdout)
 	if err != nil {
-		return outcome, err
-	}
-
-	err = os.Rename(tempOutputPath, outputPath)
-	if err != nil {
-		return outcome, fmt.Errorf("renaming temp output file to final output file: %w", err)
-	}
-
-	return outcome, nil
-}
-
-func sizeCheck(inputPath, outputPath string,
-	stats *stats.Stats) (outcome string, err error) {
-	inputSize, outputSize, err := size.GetSizes(inputPath, outputPath)
-	if err != nil {
-		return "", err
-	}
-
-	stats.InputSize += inputSize
-	stats.OutputSize += outputSize
-
-	outcome = "✔️  (" + size.DiffString(outputSize, inputSize) + ")"
-
-	if outputSize <= inputSize {
-		return outcome, nil
-	}
-
-	outcome += " 😑 Replacing output with input..."
-	err = size.ReplaceBy(outputPath, inputPath)
-	if err != nil {
-		return outcome, err
+		return err
 	}
-	stats.OutputSize += inputSize - outputSize
-	outcome += " ✔️"
-	return outcome, nil
-}
+	defer func() {
+		_ = processor.Close()
+	}()
 
-func warnSignErr(err error) string {
-	return " ⚠️  " + err.Error()
+	server := server.New(settings.Server, settings.InputDirPath,
+		uploadDirPath, processor, logger)
+	return server.Run(ctx)
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitCommand(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		args     []string
+		command  string
+		flagArgs []string
+	}{
+		"no command": {
+			args:     []string{"tinier", "-config", "x.yaml"},
+			flagArgs: []string{"tinier", "-config", "x.yaml"},
+		},
+		"command only": {
+			args:     []string{"tinier", "serve"},
+			command:  "serve",
+			flagArgs: []string{"tinier"},
+		},
+		"command before flags": {
+			args:     []string{"tinier", "serve", "-config", "x.yaml"},
+			command:  "serve",
+			flagArgs: []string{"tinier", "-config", "x.yaml"},
+		},
+		"command after flags": {
+			args:     []string{"tinier", "-config", "x.yaml", "serve"},
+			command:  "serve",
+			flagArgs: []string{"tinier", "-config", "x.yaml"},
+		},
+		"command after boolean flag": {
+			args:     []string{"tinier", "-watch", "serve"},
+			command:  "serve",
+			flagArgs: []string{"tinier", "-watch"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			command, flagArgs := splitCommand(testCase.args)
+
+			assert.Equal(t, testCase.command, command)
+			assert.Equal(t, testCase.flagArgs, flagArgs)
+		})
+	}
+}
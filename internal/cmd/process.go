@@ -0,0 +1,7 @@
+package cmd
+
+import "time"
+
+// waitDelay is the maximum duration to wait for the output pipes
+// of a command to be closed once its context is canceled.
+const waitDelay = time.Second
@@ -0,0 +1,14 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os/exec"
+)
+
+// SetProcessGroup only sets the command to stop waiting for its
+// output shortly after its context is canceled, since process
+// groups are not supported on Windows.
+func SetProcessGroup(execCmd *exec.Cmd) {
+	execCmd.WaitDelay = waitDelay
+}
@@ -6,6 +6,7 @@ import (
 
 type Runner interface {
 	Run(cmd ExecCmd) (output string, err error)
+	Stream(cmd ExecCmd, onStdoutLine, onStderrLine func(line string)) (output string, err error)
 }
 
 // Run runs a command in a blocking manner, returning its output and
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// maxRetainedLines and maxRetainedBytes are the maximum number of
+// the last standard error lines, and of their bytes, retained to be
+// returned for error messages.
+const (
+	maxRetainedLines = 50
+	maxRetainedBytes = 16 * 1024
+)
+
+// Stream runs a command in a blocking manner, calling onStdoutLine
+// and onStderrLine for each line written to its standard output and
+// standard error respectively, as soon as it is written. Either
+// function can be nil to ignore the corresponding output.
+// It returns the last lines written to the standard error, capped
+// to 50 lines and 16KiB, and an error if it failed.
+// Use SetProcessGroup on the command for the whole process group
+// to be killed if the command context is canceled.
+func (c *Cmd) Stream(cmd ExecCmd, onStdoutLine, onStderrLine func(line string)) (
+	output string, err error) {
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("getting stdout pipe: %w", err)
+	}
+
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("getting stderr pipe: %w", err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return "", fmt.Errorf("starting command: %w", err)
+	}
+
+	stderrTail := newTail(maxRetainedLines, maxRetainedBytes)
+	onStderrLineAndRetain := func(line string) {
+		stderrTail.add(line)
+		if onStderrLine != nil {
+			onStderrLine(line)
+		}
+	}
+
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		streamLines(stderrPipe, onStderrLineAndRetain)
+	}()
+	streamLines(stdoutPipe, onStdoutLine)
+	wg.Wait()
+
+	err = cmd.Wait()
+	return stderrTail.String(), err
+}
+
+// streamLines calls onLine for each line read from the reader,
+// until the reader is closed. Lines longer than maxLineLength
+// are truncated.
+func streamLines(reader io.Reader, onLine func(line string)) {
+	if onLine == nil {
+		_, _ = io.Copy(io.Discard, reader)
+		return
+	}
+
+	bufferedReader := bufio.NewReader(reader)
+	for {
+		line, err := readLine(bufferedReader)
+		if line != "" || err == nil {
+			onLine(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// maxLineLength is the maximum length of a line passed
+// to a line callback, beyond which the line is truncated.
+const maxLineLength = 64 * 1024
+
+func readLine(reader *bufio.Reader) (line string, err error) {
+	builder := new(strings.Builder)
+	for {
+		fragment, isPrefix, err := reader.ReadLine()
+		if builder.Len() < maxLineLength {
+			remaining := maxLineLength - builder.Len()
+			if len(fragment) > remaining {
+				fragment = fragment[:remaining]
+			}
+			builder.Write(fragment)
+		}
+		if err != nil || !isPrefix {
+			return builder.String(), err
+		}
+	}
+}
+
+// tail retains the last lines added to it, up to a maximum
+// number of lines and a maximum number of bytes for these lines.
+type tail struct {
+	lines    []string
+	size     int
+	maxLines int
+	maxBytes int
+	dropped  bool
+	mutex    sync.Mutex
+}
+
+func newTail(maxLines, maxBytes int) *tail {
+	return &tail{
+		maxLines: maxLines,
+		maxBytes: maxBytes,
+	}
+}
+
+func (t *tail) add(line string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(line) > t.maxBytes {
+		line = line[:t.maxBytes]
+	}
+	t.lines = append(t.lines, line)
+	t.size += len(line)
+	for len(t.lines) > t.maxLines || t.size > t.maxBytes {
+		t.size -= len(t.lines[0])
+		t.lines = t.lines[1:]
+		t.dropped = true
+	}
+}
+
+func (t *tail) String() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s := strings.Join(t.lines, "\n")
+	if t.dropped {
+		s = "...\n" + s
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Cmd_Stream(t *testing.T) {
+	t.Parallel()
+
+	errDummy := errors.New("dummy")
+
+	var manyLines, lastLines []string
+	for i := 0; i < maxRetainedLines+10; i++ {
+		line := fmt.Sprint("error ", i)
+		manyLines = append(manyLines, line)
+		if i >= 10 {
+			lastLines = append(lastLines, line)
+		}
+	}
+
+	halfLine := strings.Repeat("x", maxRetainedBytes/2)
+
+	testCases := map[string]struct {
+		stdout      string
+		stderr      string
+		startErr    error
+		waitErr     error
+		stdoutLines []string
+		stderrLines []string
+		output      string
+		errMessage  string
+	}{
+		"start error": {
+			startErr:   errDummy,
+			errMessage: "starting command: dummy",
+		},
+		"success": {
+			stdout:      "line 1\n\nline 2\n",
+			stderr:      "warning",
+			stdoutLines: []string{"line 1", "", "line 2"},
+			stderrLines: []string{"warning"},
+			output:      "warning",
+		},
+		"wait error": {
+			stdout:      "line 1",
+			stderr:      "some error\n",
+			waitErr:     errDummy,
+			stdoutLines: []string{"line 1"},
+			stderrLines: []string{"some error"},
+			output:      "some error",
+			errMessage:  "dummy",
+		},
+		"retained output capped": {
+			stderr:      strings.Join(manyLines, "\n"),
+			stderrLines: manyLines,
+			output:      "...\n" + strings.Join(lastLines, "\n"),
+		},
+		"retained output capped in bytes": {
+			stderr:      "first\n" + halfLine + "\n" + halfLine + "\nlast",
+			stderrLines: []string{"first", halfLine, halfLine, "last"},
+			output:      "...\n" + halfLine + "\nlast",
+		},
+		"long line truncated": {
+			stdout:      strings.Repeat("a", maxLineLength+1) + "\nb",
+			stdoutLines: []string{strings.Repeat("a", maxLineLength), "b"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+
+			mockCmd := NewMockExecCmd(ctrl)
+			mockCmd.EXPECT().StdoutPipe().
+				Return(io.NopCloser(strings.NewReader(testCase.stdout)), nil)
+			mockCmd.EXPECT().StderrPipe().
+				Return(io.NopCloser(strings.NewReader(testCase.stderr)), nil)
+			mockCmd.EXPECT().Start().Return(testCase.startErr)
+			if testCase.startErr == nil {
+				mockCmd.EXPECT().Wait().Return(testCase.waitErr)
+			}
+
+			var stdoutLines, stderrLines []string
+			onStdoutLine := func(line string) {
+				stdoutLines = append(stdoutLines, line)
+			}
+			onStderrLine := func(line string) {
+				stderrLines = append(stderrLines, line)
+			}
+
+			cmd := &Cmd{}
+			output, err := cmd.Stream(mockCmd, onStdoutLine, onStderrLine)
+
+			if testCase.errMessage != "" {
+				require.Error(t, err)
+				assert.Equal(t, testCase.errMessage, err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.stdoutLines, stdoutLines)
+			assert.Equal(t, testCase.stderrLines, stderrLines)
+			assert.Equal(t, testCase.output, output)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Cmd_Stream_cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The child sleep process keeps the output pipes open,
+	// so the stream only ends if the process group is killed.
+	execCmd := exec.CommandContext(ctx, "sh", "-c", "echo started; sleep 30 & wait")
+	SetProcessGroup(execCmd)
+
+	onStdoutLine := func(line string) {
+		if line == "started" {
+			cancel()
+		}
+	}
+
+	cmd := &Cmd{}
+	start := time.Now()
+	_, err := cmd.Stream(execCmd, onStdoutLine, nil)
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), waitDelay)
+}
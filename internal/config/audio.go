@@ -14,21 +14,33 @@ type Audio struct {
 	// Extensions is the list of audio file extensions to convert
 	// from the input directory. Audio files with file extensions
 	// not listed are simply copied to the output directory.
-	Extensions []string
+	Extensions []string `yaml:"extensions" json:"extensions"`
 	// OutputExtension is the output extension to set on converted
 	// audio files. If defaults to `.opus`.
-	OutputExtension string
-	QScale          *uint
-	Codec           string
+	OutputExtension string `yaml:"output_extension" json:"output_extension"`
+	QScale          *uint  `yaml:"qscale" json:"qscale"`
+	Codec           string `yaml:"codec" json:"codec"`
+	// FallbackCodec is the codec to use instead of Codec if the ffmpeg
+	// used is not built with the Codec encoder. It defaults to the empty
+	// string, in which case tinier fails at start if Codec is unavailable.
+	FallbackCodec string `yaml:"fallback_codec" json:"fallback_codec"`
 	// BitRate is the bitrate string to use for the codec.
 	// It defaults to 32k if the libopus codec is used.
 	// It can be set to the empty string so the qscale parameter is used
 	// instead of the bitrate.
-	BitRate *string
-	Skip    *bool
+	BitRate *string `yaml:"bitrate" json:"bitrate"`
+	Skip    *bool   `yaml:"skip" json:"skip"`
+	// TinyKbps is the maximum bit rate in kbps of an audio file
+	// already encoded with the codec for it to be considered already
+	// tiny and copied as is instead of being converted.
+	// It defaults to 64 and can be set to 0 to disable it.
+	TinyKbps *uint `yaml:"tiny_kbps" json:"tiny_kbps"`
+	// Workers is the maximum number of audio files to process
+	// concurrently. It defaults to the global workers setting.
+	Workers *uint `yaml:"workers" json:"workers"`
 }
 
-func (a *Audio) setDefaults() {
+func (a *Audio) setDefaults(defaultWorkers uint) {
 	a.Extensions = gosettings.DefaultSlice(a.Extensions, []string{".mp3", ".flac"})
 	a.OutputExtension = gosettings.DefaultComparable(a.OutputExtension, ".opus")
 	const defaultQScale = 5
@@ -40,6 +52,9 @@ func (a *Audio) setDefaults() {
 		a.BitRate = gosettings.DefaultPointer(a.BitRate, "")
 	}
 	a.Skip = gosettings.DefaultPointer(a.Skip, false)
+	const defaultTinyKbps = 64
+	a.TinyKbps = gosettings.DefaultPointer(a.TinyKbps, defaultTinyKbps)
+	a.Workers = gosettings.DefaultPointer(a.Workers, defaultWorkers)
 }
 
 func (a *Audio) overrideWith(other Audio) {
@@ -47,8 +62,11 @@ func (a *Audio) overrideWith(other Audio) {
 	a.OutputExtension = gosettings.OverrideWithComparable(a.OutputExtension, other.OutputExtension)
 	a.QScale = gosettings.OverrideWithPointer(a.QScale, other.QScale)
 	a.Codec = gosettings.OverrideWithComparable(a.Codec, other.Codec)
+	a.FallbackCodec = gosettings.OverrideWithComparable(a.FallbackCodec, other.FallbackCodec)
 	a.BitRate = gosettings.OverrideWithPointer(a.BitRate, other.BitRate)
 	a.Skip = gosettings.OverrideWithPointer(a.Skip, other.Skip)
+	a.TinyKbps = gosettings.OverrideWithPointer(a.TinyKbps, other.TinyKbps)
+	a.Workers = gosettings.OverrideWithPointer(a.Workers, other.Workers)
 }
 
 var ErrBitRateNotSet = errors.New("bit rate is not set")
@@ -56,22 +74,27 @@ var ErrBitRateNotSet = errors.New("bit rate is not set")
 func (a *Audio) validate() (err error) {
 	err = validate.AllMatchRegex(a.Extensions, regexExtension)
 	if err != nil {
-		return fmt.Errorf("malformed audio file extension: %w", err)
+		return fmt.Errorf("extensions: %w", err)
 	}
 
 	err = validate.MatchRegex(a.OutputExtension, regexExtension)
 	if err != nil {
-		return fmt.Errorf("malformed audio output extension: %w", err)
+		return fmt.Errorf("output_extension: %w", err)
 	}
 
 	const minQScale, maxQScale = 0, 9
 	err = validate.NumberBetween(*a.QScale, minQScale, maxQScale)
 	if err != nil {
-		return fmt.Errorf("audio quality scale: %w", err)
+		return fmt.Errorf("qscale: %w", err)
 	}
 
 	if a.Codec == "libopus" && *a.BitRate == "" { // bit rate is required for libopus
-		return fmt.Errorf("%w: for audio codec %s", ErrBitRateNotSet, a.Codec)
+		return fmt.Errorf("bitrate: %w: for audio codec %s", ErrBitRateNotSet, a.Codec)
+	}
+
+	err = validateWorkers(*a.Workers)
+	if err != nil {
+		return fmt.Errorf("workers: %w", err)
 	}
 
 	return nil
@@ -85,22 +108,39 @@ func (a *Audio) toLinesNode() *gotree.Node {
 	node := gotree.New("Audio files:")
 	node.Appendf("Input file extensions: %s", andStrings(a.Extensions))
 	node.Appendf("Output file extension: %s", a.OutputExtension)
-	node.Appendf("Codec: %s", a.Codec)
+	codecNode := node.Appendf("Codec: %s", a.Codec)
+	if a.FallbackCodec != "" {
+		codecNode.Appendf("Fallback codec: %s", a.FallbackCodec)
+	}
 	if *a.BitRate != "" {
 		node.Appendf("Bitrate: %s", *a.BitRate)
 	} else {
 		node.Appendf("Constant quantizer qscale: %d", *a.QScale)
 	}
+	if *a.TinyKbps == 0 {
+		node.Appendf("Already tiny threshold: disabled")
+	} else {
+		node.Appendf("Already tiny threshold: %d kbps", *a.TinyKbps)
+	}
+	node.Appendf("Workers: %d", *a.Workers)
 
 	return node
 }
 
+// Fingerprint returns a string made of the settings affecting
+// the conversion of an audio file.
+func (a *Audio) Fingerprint() string {
+	return fmt.Sprintf("ext=%s codec=%s qscale=%d bitrate=%s tinykbps=%d",
+		a.OutputExtension, a.Codec, *a.QScale, *a.BitRate, *a.TinyKbps)
+}
+
 func (a *Audio) String() string {
 	return a.toLinesNode().String()
 }
 
 func (a *Audio) read(reader *reader.Reader) (err error) {
 	a.Codec = reader.String("AUDIO_CODEC")
+	a.FallbackCodec = reader.String("AUDIO_FALLBACK_CODEC")
 	a.OutputExtension = reader.String("AUDIO_OUTPUT_EXTENSION")
 
 	a.Extensions = reader.CSV("AUDIO_EXTENSIONS")
@@ -116,5 +156,16 @@ func (a *Audio) read(reader *reader.Reader) (err error) {
 	}
 
 	a.BitRate = reader.Get("AUDIO_BITRATE")
+
+	a.TinyKbps, err = reader.UintPtr("AUDIO_TINY_KBPS")
+	if err != nil {
+		return err
+	}
+
+	a.Workers, err = reader.UintPtr("AUDIO_WORKERS")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Capabilities is the set of encoders and muxers available
+// in the ffmpeg build used.
+type Capabilities interface {
+	HasEncoder(name string) bool
+	HasMuxer(name string) bool
+}
+
+var (
+	ErrEncoderUnavailable = errors.New("ffmpeg is not built with the encoder")
+	ErrMuxerUnavailable   = errors.New("ffmpeg is not built with the muxer")
+	ErrCodecContainer     = errors.New("codec cannot be written in the container")
+)
+
+// extensionToMuxer maps output file extensions to the ffmpeg muxer
+// picked for them. Extensions not listed are not checked.
+var extensionToMuxer = map[string]string{ //nolint:gochecknoglobals
+	".mp4":  "mp4",
+	".m4v":  "mp4",
+	".mov":  "mov",
+	".mkv":  "matroska",
+	".webm": "webm",
+	".avi":  "avi",
+	".jpg":  "image2",
+	".jpeg": "image2",
+	".png":  "image2",
+	".jxl":  "image2",
+	".avif": "avif",
+	".webp": "webp",
+	".mp3":  "mp3",
+	".opus": "opus",
+	".ogg":  "ogg",
+	".m4a":  "ipod",
+	".aac":  "adts",
+	".flac": "flac",
+}
+
+// extensionToEncoders maps output file extensions to the only ffmpeg
+// encoders whose output can be written in their container. Extensions
+// not listed accept any encoder.
+var extensionToEncoders = map[string][]string{ //nolint:gochecknoglobals
+	".webm": {"libvpx", "libvpx-vp9", "libaom-av1", "libsvtav1", "librav1e",
+		"libopus", "libvorbis"},
+	".jpg":  {"mjpeg"},
+	".jpeg": {"mjpeg"},
+	".png":  {"png"},
+	".jxl":  {"libjxl"},
+	".avif": {"libaom-av1", "libsvtav1", "librav1e"},
+	".webp": {"libwebp"},
+	".mp3":  {"libmp3lame"},
+	".opus": {"libopus", "opus"},
+	".ogg":  {"libopus", "opus", "libvorbis", "flac"},
+	".m4a":  {"aac", "libfdk_aac", "alac"},
+	".aac":  {"aac", "libfdk_aac"},
+	".flac": {"flac"},
+}
+
+// ValidateCapabilities checks the codec of each media type not skipped
+// is available in the capabilities given, and can be written in the
+// container of its output file extension. If a codec is not available
+// and a fallback codec is set, the codec is replaced by the fallback
+// codec, and a warning is returned for it.
+// The settings must have their defaults set and be valid.
+func (s *Settings) ValidateCapabilities(capabilities Capabilities) (
+	warnings []string, err error) {
+	type mediaCodec struct {
+		name            string
+		skip            bool
+		codec           *string
+		fallbackCodec   string
+		outputExtension *string
+	}
+	mediaCodecs := []mediaCodec{
+		{"video", *s.Video.Skip, &s.Video.Codec, s.Video.FallbackCodec, &s.Video.OutputExtension},
+		{"image", *s.Image.Skip, &s.Image.Codec, s.Image.FallbackCodec, &s.Image.OutputExtension},
+		{"audio", *s.Audio.Skip, &s.Audio.Codec, s.Audio.FallbackCodec, &s.Audio.OutputExtension},
+		{"image alpha", *s.Image.Skip || s.Image.AlphaCodec == AlphaCodecNone,
+			&s.Image.AlphaCodec, "", &s.Image.AlphaOutputExtension},
+	}
+
+	var fellBack bool
+	for _, media := range mediaCodecs {
+		if media.skip {
+			continue
+		}
+
+		if !capabilities.HasEncoder(*media.codec) && media.fallbackCodec != "" &&
+			capabilities.HasEncoder(media.fallbackCodec) {
+			warnings = append(warnings, fmt.Sprintf(
+				"ffmpeg is not built with the %s codec %s, falling back to %s",
+				media.name, *media.codec, media.fallbackCodec))
+			if media.name == "image" {
+				s.Image.fallBack(media.fallbackCodec)
+			}
+			*media.codec = media.fallbackCodec
+			fellBack = true
+		}
+
+		err = validateCodecCapabilities(*media.codec, *media.outputExtension, capabilities)
+		if err != nil {
+			return nil, fmt.Errorf("%s settings: %w", media.name, err)
+		}
+	}
+
+	if fellBack {
+		err = s.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("settings with fallback codecs: %w", err)
+		}
+	}
+
+	return warnings, nil
+}
+
+func validateCodecCapabilities(codec, outputExtension string,
+	capabilities Capabilities) (err error) {
+	if !capabilities.HasEncoder(codec) {
+		return fmt.Errorf("%w: %s", ErrEncoderUnavailable, codec)
+	}
+
+	outputExtension = strings.ToLower(outputExtension)
+	muxer, ok := extensionToMuxer[outputExtension]
+	if ok && !capabilities.HasMuxer(muxer) {
+		return fmt.Errorf("%w: %s for output extension %s",
+			ErrMuxerUnavailable, muxer, outputExtension)
+	}
+
+	encoders, ok := extensionToEncoders[outputExtension]
+	if !ok {
+		return nil
+	}
+	for _, encoder := range encoders {
+		if encoder == codec {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s for output extension %s, it must be one of %s",
+		ErrCodecContainer, codec, outputExtension, orStrings(encoders))
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/qdm12/gosettings/validate"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCapabilities struct {
+	encoders []string
+	muxers   []string
+}
+
+func (c testCapabilities) HasEncoder(name string) bool {
+	for _, encoder := range c.encoders {
+		if encoder == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c testCapabilities) HasMuxer(name string) bool {
+	for _, muxer := range c.muxers {
+		if muxer == name {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_Settings_ValidateCapabilities(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	capabilities := testCapabilities{
+		encoders: []string{"libsvtav1", "libx264", "mjpeg", "png", "libwebp", "libopus"},
+		muxers:   []string{"mp4", "webm", "image2", "webp", "opus"},
+	}
+
+	testCases := map[string]struct {
+		settings   Settings
+		expected   Settings
+		warnings   []string
+		errWrapped error
+		errMessage string
+	}{
+		"defaults": {},
+		"skipped media type not checked": {
+			settings: Settings{Audio: Audio{Codec: "libfdk_aac", Skip: ptrTo(true)}},
+			expected: Settings{Audio: Audio{Codec: "libfdk_aac", Skip: ptrTo(true)}},
+		},
+		"encoder unavailable": {
+			settings:   Settings{Audio: Audio{Codec: "libfdk_aac"}},
+			errWrapped: ErrEncoderUnavailable,
+			errMessage: "audio settings: ffmpeg is not built with the encoder: libfdk_aac",
+		},
+		"muxer unavailable": {
+			settings:   Settings{Video: Video{OutputExtension: ".mkv"}},
+			errWrapped: ErrMuxerUnavailable,
+			errMessage: "video settings: ffmpeg is not built with the muxer: " +
+				"matroska for output extension .mkv",
+		},
+		"codec not writable in container": {
+			settings:   Settings{Image: Image{Codec: "libwebp", OutputExtension: ".jpg"}},
+			errWrapped: ErrCodecContainer,
+			errMessage: "image settings: codec cannot be written in the container: " +
+				"libwebp for output extension .jpg, it must be one of mjpeg",
+		},
+		"image fallback codec": {
+			settings: Settings{Image: Image{Codec: "libjxl", FallbackCodec: "libwebp"}},
+			expected: Settings{Image: Image{Codec: "libwebp", FallbackCodec: "libwebp",
+				OutputExtension: ".webp"}},
+			warnings: []string{"ffmpeg is not built with the image codec libjxl, falling back to libwebp"},
+		},
+		"alpha codec unavailable": {
+			settings:   Settings{Image: Image{AlphaCodec: "libjxl"}},
+			errWrapped: ErrEncoderUnavailable,
+			errMessage: "image alpha settings: ffmpeg is not built with the encoder: libjxl",
+		},
+		"alpha codec disabled": {
+			settings: Settings{Image: Image{AlphaCodec: AlphaCodecNone}},
+			expected: Settings{Image: Image{AlphaCodec: AlphaCodecNone}},
+		},
+		"invalid settings with fallback codec": {
+			settings: Settings{Video: Video{Codec: "libvpx-vp9", FallbackCodec: "libx264",
+				OutputExtension: ".mp4"}},
+			errWrapped: validate.ErrValueNotOneOf,
+			errMessage: "settings with fallback codecs: video.preset: " +
+				"unknown for codec libx264: value is not one of the possible choices: " +
+				"8 must be one of ultrafast, superfast, veryfast, faster, fast, medium, " +
+				"slow, slower, veryslow or placebo",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings := testCase.settings
+			settings.InputDirPath = inputDir
+			settings.OutputDirPath = inputDir + "_output"
+			settings.SetDefaults()
+			expected := testCase.expected
+			expected.InputDirPath = inputDir
+			expected.OutputDirPath = inputDir + "_output"
+			expected.SetDefaults()
+
+			warnings, err := settings.ValidateCapabilities(capabilities)
+
+			assert.Equal(t, testCase.warnings, warnings)
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+				return
+			}
+			assert.Equal(t, expected, settings)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/qdm12/tinier/internal/models"
+)
+
+// DirFilename is the file name of settings files placed in
+// input subdirectories, to override settings for files beneath.
+const DirFilename = ".tinier.yaml"
+
+// RemoveDirFiles returns the file paths given without the
+// directory settings files, and whether any was found.
+func RemoveDirFiles(filePaths []string) (kept []string, found bool) {
+	kept = make([]string, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		if filepath.Base(filePath) == DirFilename {
+			found = true
+			continue
+		}
+		kept = append(kept, filePath)
+	}
+	return kept, found
+}
+
+// DirResolver resolves the effective settings for an input file,
+// by overriding the base settings with the settings files found in
+// the directories between the root input directory and the file.
+// It is safe for concurrent use.
+type DirResolver struct {
+	base         Settings
+	raw          Settings
+	capabilities Capabilities
+	rootDir      string
+	logger       DebugLogger
+	mutex        sync.Mutex
+	// dirToSettings caches the effective settings for each directory.
+	dirToSettings map[string]dirResult
+}
+
+type dirResult struct {
+	settings Settings
+	// raw is the settings before their defaults are set, with
+	// the directory settings files merged onto them.
+	raw        Settings
+	overridden bool
+}
+
+// NewDirResolver creates a directory settings resolver, where the
+// base settings given must have their defaults set and be valid,
+// and the raw settings given are the same settings before their
+// defaults are set. Directory settings files are merged onto the
+// raw settings before setting their defaults, such that defaults
+// depending on other fields, such as the image output extension
+// depending on the image codec, follow the directory settings.
+// The capabilities given are used to check the codecs of each
+// directory settings, and can be nil to skip this check.
+func NewDirResolver(base, raw Settings, capabilities Capabilities,
+	logger DebugLogger) *DirResolver {
+	return &DirResolver{
+		base:          base,
+		raw:           raw,
+		capabilities:  capabilities,
+		rootDir:       filepath.Clean(base.InputDirPath),
+		logger:        logger,
+		dirToSettings: make(map[string]dirResult),
+	}
+}
+
+// Reset clears the cached settings, for example if a directory
+// settings file is created or modified.
+func (r *DirResolver) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.dirToSettings = make(map[string]dirResult)
+}
+
+// Resolve returns the effective settings for the input file path
+// given, and logs them at the debug level if they differ from the
+// base settings.
+func (r *DirResolver) Resolve(filePath string, mediaType models.MediaType) (
+	settings Settings, err error) {
+	r.mutex.Lock()
+	result, err := r.resolveDir(filepath.Dir(filepath.Clean(filePath)))
+	r.mutex.Unlock()
+	if err != nil {
+		return settings, err
+	}
+
+	if result.overridden {
+		r.logger.Debug(fmt.Sprintf("effective settings for %s:\n%s",
+			filePath, result.settings.mediaTypeString(mediaType)))
+	}
+
+	return result.settings, nil
+}
+
+func (r *DirResolver) resolveDir(dirPath string) (result dirResult, err error) {
+	result, ok := r.dirToSettings[dirPath]
+	if ok {
+		return result, nil
+	}
+
+	if dirPath == r.rootDir || !strings.HasPrefix(dirPath, r.rootDir+string(filepath.Separator)) {
+		// Settings files are ignored in the root directory
+		// since the settings file should be used instead.
+		result = dirResult{settings: r.base, raw: r.raw}
+		r.dirToSettings[dirPath] = result
+		return result, nil
+	}
+
+	result, err = r.resolveDir(filepath.Dir(dirPath))
+	if err != nil {
+		return result, err
+	}
+
+	settingsPath := filepath.Join(dirPath, DirFilename)
+	dirSettings, err := readDirFile(settingsPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return result, err
+	default:
+		result.raw.OverrideWith(dirSettings)
+		result.settings, err = r.makeSettings(result.raw)
+		if err != nil {
+			return result, fmt.Errorf("settings with %s: %w", settingsPath, err)
+		}
+		result.overridden = true
+	}
+
+	r.dirToSettings[dirPath] = result
+	return result, nil
+}
+
+// makeSettings returns the base settings with their video, image
+// and audio settings taken from the raw settings given, once their
+// defaults are set. The settings returned are validated and their
+// codecs are checked against the capabilities, if any.
+func (r *DirResolver) makeSettings(raw Settings) (settings Settings, err error) {
+	raw.SetDefaults()
+	settings = r.base
+	settings.Video = raw.Video
+	settings.Image = raw.Image
+	settings.Audio = raw.Audio
+
+	err = settings.Validate()
+	if err != nil {
+		return settings, err
+	}
+
+	if r.capabilities == nil {
+		return settings, nil
+	}
+
+	warnings, err := settings.ValidateCapabilities(r.capabilities)
+	if err != nil {
+		return settings, err
+	}
+	for _, warning := range warnings {
+		r.logger.Debug(warning)
+	}
+	return settings, nil
+}
+
+var ErrDirSettingNotSupported = errors.New("setting not supported in directory settings file")
+
+// readDirFile reads the directory settings file at the given path,
+// and returns an error wrapping os.ErrNotExist if it does not exist.
+// Only the video, image and audio conversion settings can be set
+// in the file.
+func readDirFile(path string) (settings Settings, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return settings, err
+	}
+
+	var dirSettings struct {
+		Video Video `yaml:"video"`
+		Image Image `yaml:"image"`
+		Audio Audio `yaml:"audio"`
+	}
+	err = decodeYAMLInto(file, &dirSettings)
+	_ = file.Close()
+	if err != nil {
+		return settings, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	unsupportedKeys := []struct {
+		key   string
+		isSet bool
+	}{
+		{"video.extensions", dirSettings.Video.Extensions != nil},
+		{"video.skip", dirSettings.Video.Skip != nil},
+		{"video.workers", dirSettings.Video.Workers != nil},
+		{"image.extensions", dirSettings.Image.Extensions != nil},
+		{"image.skip", dirSettings.Image.Skip != nil},
+		{"image.workers", dirSettings.Image.Workers != nil},
+		{"audio.extensions", dirSettings.Audio.Extensions != nil},
+		{"audio.skip", dirSettings.Audio.Skip != nil},
+		{"audio.workers", dirSettings.Audio.Workers != nil},
+	}
+	for _, unsupportedKey := range unsupportedKeys {
+		if unsupportedKey.isSet {
+			return settings, fmt.Errorf("%w: %s in %s",
+				ErrDirSettingNotSupported, unsupportedKey.key, path)
+		}
+	}
+
+	settings.Video = dirSettings.Video
+	settings.Image = dirSettings.Image
+	settings.Audio = dirSettings.Audio
+	return settings, nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(string) {}
+
+func Test_DirResolver_Resolve(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	writeFile := func(path, content string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		require.NoError(t, err)
+		err = os.WriteFile(path, []byte(content), 0600)
+		require.NoError(t, err)
+	}
+	writeFile(filepath.Join(rootDir, DirFilename), "video:\n  crf: 10\n")
+	writeFile(filepath.Join(rootDir, "a", DirFilename), "video:\n  crf: 40\n")
+	writeFile(filepath.Join(rootDir, "a", "b", DirFilename), "video:\n  preset: \"4\"\n")
+	writeFile(filepath.Join(rootDir, "c", DirFilename), "video:\n  workers: 2\n")
+	writeFile(filepath.Join(rootDir, "d", DirFilename), "image:\n  codec: libwebp\n")
+	writeFile(filepath.Join(rootDir, "e", DirFilename), "image:\n  codec: libjxl\n")
+
+	raw := Settings{InputDirPath: rootDir}
+	base := raw
+	base.SetDefaults()
+	capabilities := testCapabilities{
+		encoders: []string{"libsvtav1", "mjpeg", "png", "libwebp", "libopus"},
+		muxers:   []string{"mp4", "image2", "webp", "opus"},
+	}
+	resolver := NewDirResolver(base, raw, capabilities, noopLogger{})
+
+	testCases := map[string]struct {
+		filePath             string
+		crf                  uint
+		preset               string
+		imageOutputExtension string
+		imageAlphaCodec      string
+		errWrapped           error
+	}{
+		"root directory": {
+			filePath:             filepath.Join(rootDir, "x.mp4"),
+			crf:                  *base.Video.Crf,
+			preset:               base.Video.Preset,
+			imageOutputExtension: ".jpg",
+			imageAlphaCodec:      "png",
+		},
+		"subdirectory": {
+			filePath:             filepath.Join(rootDir, "a", "x.mp4"),
+			crf:                  40,
+			preset:               base.Video.Preset,
+			imageOutputExtension: ".jpg",
+			imageAlphaCodec:      "png",
+		},
+		"nested subdirectory": {
+			filePath:             filepath.Join(rootDir, "a", "b", "x.mp4"),
+			crf:                  40,
+			preset:               "4",
+			imageOutputExtension: ".jpg",
+			imageAlphaCodec:      "png",
+		},
+		"unsupported setting": {
+			filePath:   filepath.Join(rootDir, "c", "x.mp4"),
+			errWrapped: ErrDirSettingNotSupported,
+		},
+		"image codec only": {
+			filePath:             filepath.Join(rootDir, "d", "x.jpg"),
+			crf:                  *base.Video.Crf,
+			preset:               base.Video.Preset,
+			imageOutputExtension: ".webp",
+			imageAlphaCodec:      "libwebp",
+		},
+		"image codec unavailable": {
+			filePath:   filepath.Join(rootDir, "e", "x.jpg"),
+			errWrapped: ErrEncoderUnavailable,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings, err := resolver.Resolve(testCase.filePath, models.MediaTypeVideo)
+
+			if testCase.errWrapped != nil {
+				assert.ErrorIs(t, err, testCase.errWrapped)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.crf, *settings.Video.Crf)
+			assert.Equal(t, testCase.preset, settings.Video.Preset)
+			assert.Equal(t, testCase.imageOutputExtension, settings.Image.OutputExtension)
+			assert.Equal(t, testCase.imageAlphaCodec, settings.Image.AlphaCodec)
+		})
+	}
+}
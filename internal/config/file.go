@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qdm12/gosettings/reader"
+	"gopkg.in/yaml.v3"
+)
+
+// ReadFilePath returns the settings file path read from the
+// reader, which is the empty string if it is not set.
+func ReadFilePath(reader *reader.Reader) (path string) {
+	return reader.String("CONFIG", keepCase())
+}
+
+var ErrFileExtensionNotSupported = errors.New("settings file extension not supported")
+
+// ReadFile reads settings from the YAML file at the given path.
+// Unknown keys and values of the wrong type produce an error
+// indicating the line of the offending key.
+func ReadFile(path string) (settings Settings, err error) {
+	extension := strings.ToLower(filepath.Ext(path))
+	switch extension {
+	case ".yaml", ".yml":
+	default:
+		return settings, fmt.Errorf("%w: %q must be one of .yaml or .yml",
+			ErrFileExtensionNotSupported, extension)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return settings, fmt.Errorf("opening settings file: %w", err)
+	}
+
+	settings, err = decodeYAML(file)
+	if err != nil {
+		_ = file.Close()
+		return settings, fmt.Errorf("decoding settings file %s: %w", path, err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return settings, fmt.Errorf("closing settings file: %w", err)
+	}
+
+	return settings, nil
+}
+
+func decodeYAML(reader io.Reader) (settings Settings, err error) {
+	err = decodeYAMLInto(reader, &settings)
+	return settings, err
+}
+
+func decodeYAMLInto(reader io.Reader, v any) (err error) {
+	decoder := yaml.NewDecoder(reader)
+	decoder.KnownFields(true)
+	err = decoder.Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) { // EOF for an empty file
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeYAML(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		yaml       string
+		settings   Settings
+		errMessage string
+	}{
+		"empty": {},
+		"all sections": {
+			yaml: `
+input_dir_path: /input
+override_output: true
+watch_stability_period: 10s
+video:
+  crf: 30
+  extensions: [.mp4, .mkv]
+image:
+  qscale: 3
+audio:
+  bitrate: 64k
+log:
+  level: debug
+`,
+			settings: Settings{
+				InputDirPath:         "/input",
+				OverrideOutput:       ptrTo(true),
+				WatchStabilityPeriod: 10 * time.Second,
+				Video: Video{
+					Crf:        ptrTo(uint(30)),
+					Extensions: []string{".mp4", ".mkv"},
+				},
+				Image: Image{QScale: 3},
+				Audio: Audio{BitRate: ptrTo("64k")},
+				Log:   Log{Level: "debug"},
+			},
+		},
+		"unknown key": {
+			yaml: `
+video:
+  crff: 30
+`,
+			errMessage: "yaml: unmarshal errors:\n  line 3: field crff not found in type config.Video",
+		},
+		"wrong value type": {
+			yaml: `
+video:
+  crf: high
+`,
+			errMessage: "yaml: unmarshal errors:\n  line 3: cannot unmarshal !!str `high` into uint",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings, err := decodeYAML(strings.NewReader(testCase.yaml))
+
+			if testCase.errMessage != "" {
+				require.Error(t, err)
+				assert.Equal(t, testCase.errMessage, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.settings, settings)
+		})
+	}
+}
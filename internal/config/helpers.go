@@ -1,5 +1,11 @@
 package config
 
+import (
+	"errors"
+
+	"github.com/qdm12/gosettings/reader"
+)
+
 func yesno(b bool) string {
 	if b {
 		return "yes"
@@ -11,6 +17,10 @@ func andStrings(strings []string) (result string) {
 	return joinStrings(strings, "and")
 }
 
+func orStrings(strings []string) (result string) {
+	return joinStrings(strings, "or")
+}
+
 func joinStrings(strings []string, lastJoin string) (result string) {
 	if len(strings) == 0 {
 		return ""
@@ -19,7 +29,7 @@ func joinStrings(strings []string, lastJoin string) (result string) {
 	result = strings[0]
 	for i := 1; i < len(strings); i++ {
 		if i < len(strings)-1 {
-			result += strings[i] + ", "
+			result += ", " + strings[i]
 		} else {
 			result += " " + lastJoin + " " + strings[i]
 		}
@@ -27,3 +37,20 @@ func joinStrings(strings []string, lastJoin string) (result string) {
 
 	return result
 }
+
+var ErrWorkersZero = errors.New("number of workers cannot be zero")
+
+func validateWorkers(workers uint) (err error) {
+	if workers == 0 {
+		return ErrWorkersZero
+	}
+	return nil
+}
+
+// keepCase returns a reader option to keep the case of
+// a value read, for example for file paths.
+func keepCase() reader.Option {
+	return reader.ForceLowercase(false)
+}
+
+func ptrTo[T any](value T) *T { return &value }
@@ -13,70 +13,196 @@ type Image struct {
 	// Extensions is the list of image file extensions to convert
 	// from the input directory. Images with file extensions not
 	// listed are simply copied to the output directory.
-	Extensions []string
+	Extensions []string `yaml:"extensions" json:"extensions"`
 	// OutputExtension is the output extension to set on converted
-	// image files. If defaults to `.jpg`.
-	OutputExtension string
-	Scale           string
-	// Codec is the codec to use, which defaults to `mjpeg`.
-	Codec string
+	// image files. If defaults to the extension matching the codec,
+	// which is `.jpg` for `mjpeg`, `.avif` for `libaom-av1` and
+	// `libsvtav1`, `.webp` for `libwebp`, `.jxl` for `libjxl` and
+	// `.png` for `png`.
+	OutputExtension string `yaml:"output_extension" json:"output_extension"`
+	Scale           string `yaml:"scale" json:"scale"`
+	// Upscale allows upscaling images smaller than the scale
+	// resolution. It defaults to false, so images are only
+	// ever downscaled.
+	Upscale *bool `yaml:"upscale" json:"upscale"`
+	// Codec is the codec to use, which is one of `mjpeg`, `libaom-av1`,
+	// `libsvtav1`, `libwebp`, `libjxl` or `png` for lossless PNG
+	// optimization, and defaults to `mjpeg`.
+	Codec string `yaml:"codec" json:"codec"`
+	// FallbackCodec is the codec to use instead of Codec if the ffmpeg
+	// used is not built with the Codec encoder. It defaults to the empty
+	// string, in which case tinier fails at start if Codec is unavailable.
+	FallbackCodec string `yaml:"fallback_codec" json:"fallback_codec"`
+	// AlphaCodec is the codec to use for images with an alpha channel,
+	// such as transparent PNG images, which is one of `png`, `libwebp`,
+	// `libjxl`, `libaom-av1`, `libsvtav1` or `none` to use Codec and
+	// flatten transparent areas. It defaults to Codec if it supports
+	// an alpha channel, and to `png` otherwise.
+	AlphaCodec string `yaml:"alpha_codec" json:"alpha_codec"`
+	// AlphaOutputExtension is the output extension to set on converted
+	// images with an alpha channel. It defaults to the extension
+	// matching AlphaCodec.
+	AlphaOutputExtension string `yaml:"alpha_output_extension" json:"alpha_output_extension"`
 	// QScale is the constant quantizer to use, which defaults to 5.
 	// Note this is only used for the `mjpeg` codec.
-	QScale uint
+	QScale uint `yaml:"qscale" json:"qscale"`
 	// CRF is the constant quality to use, which defaults to 35.
-	// Note this is only used for the `libaom-av1` codec.
+	// Note this is only used for the `libaom-av1` and `libsvtav1` codecs.
 	// See https://trac.ffmpeg.org/wiki/Encode/AV1#ConstantQuality
-	CRF  uint
-	Skip *bool
+	CRF uint `yaml:"crf" json:"crf"`
+	// WebPQuality is the quality from 0 to 100 to use, which
+	// defaults to 75. Note this is only used for the `libwebp`
+	// codec, when WebPLossless is false.
+	WebPQuality *uint `yaml:"webp_quality" json:"webp_quality"`
+	// WebPLossless encodes images losslessly with the `libwebp`
+	// codec. It defaults to false.
+	WebPLossless *bool `yaml:"webp_lossless" json:"webp_lossless"`
+	// JXLDistance is the Butteraugli distance from 0 to 25 to use,
+	// where 0 is lossless and 1 is visually lossless, and defaults
+	// to 1. Note this is only used for the `libjxl` codec.
+	JXLDistance *float64 `yaml:"jxl_distance" json:"jxl_distance"`
+	// JXLEffort is the encoding effort from 1 to 9 to use, trading
+	// speed for size, and defaults to 7. Note this is only used for
+	// the `libjxl` codec.
+	JXLEffort uint `yaml:"jxl_effort" json:"jxl_effort"`
+	// PNGCompressionLevel is the zlib compression level from 1 to 9
+	// to use, and defaults to 9 for the maximum compression.
+	// Note this is only used for the `png` codec.
+	PNGCompressionLevel uint `yaml:"png_compression_level" json:"png_compression_level"`
+	// PNGPalette stores images with at most 255 colors and no partial
+	// transparency with a palette, which is still lossless. It defaults
+	// to false. Note this is only used for the `png` codec.
+	PNGPalette *bool `yaml:"png_palette" json:"png_palette"`
+	Skip       *bool `yaml:"skip" json:"skip"`
+	// Workers is the maximum number of image files to process
+	// concurrently. It defaults to the global workers setting.
+	Workers *uint `yaml:"workers" json:"workers"`
 }
 
-func (i *Image) setDefaults() {
+func (i *Image) setDefaults(defaultWorkers uint) {
 	i.Extensions = gosettings.DefaultSlice(i.Extensions, []string{".jpg", ".jpeg", ".png", ".avif"})
-	i.OutputExtension = gosettings.DefaultComparable(i.OutputExtension, ".jpg")
-	i.Scale = gosettings.DefaultComparable(i.Scale, "1280:-1")
 	i.Codec = gosettings.DefaultComparable(i.Codec, "mjpeg")
+	i.OutputExtension = gosettings.DefaultComparable(i.OutputExtension,
+		imageCodecExtension(i.Codec))
+	i.AlphaCodec = gosettings.DefaultComparable(i.AlphaCodec, defaultAlphaCodec(i.Codec))
+	i.AlphaOutputExtension = gosettings.DefaultComparable(i.AlphaOutputExtension,
+		imageCodecExtension(i.AlphaCodec))
+	i.Scale = gosettings.DefaultComparable(i.Scale, "1280:-1")
+	i.Upscale = gosettings.DefaultPointer(i.Upscale, false)
 	const defaultQScale = 5
 	i.QScale = gosettings.DefaultComparable(i.QScale, defaultQScale)
 	const defaultCRF = 35
 	i.CRF = gosettings.DefaultComparable(i.CRF, defaultCRF)
+	const defaultWebPQuality = 75
+	i.WebPQuality = gosettings.DefaultPointer(i.WebPQuality, defaultWebPQuality)
+	i.WebPLossless = gosettings.DefaultPointer(i.WebPLossless, false)
+	i.JXLDistance = gosettings.DefaultPointer(i.JXLDistance, 1)
+	const defaultJXLEffort = 7
+	i.JXLEffort = gosettings.DefaultComparable(i.JXLEffort, defaultJXLEffort)
+	const defaultPNGCompressionLevel = 9
+	i.PNGCompressionLevel = gosettings.DefaultComparable(i.PNGCompressionLevel,
+		defaultPNGCompressionLevel)
+	i.PNGPalette = gosettings.DefaultPointer(i.PNGPalette, false)
 	i.Skip = gosettings.DefaultPointer(i.Skip, false)
+	i.Workers = gosettings.DefaultPointer(i.Workers, defaultWorkers)
 }
 
 func (i *Image) overrideWith(other Image) {
 	i.Extensions = gosettings.OverrideWithSlice(i.Extensions, other.Extensions)
 	i.OutputExtension = gosettings.OverrideWithComparable(i.OutputExtension, other.OutputExtension)
 	i.Scale = gosettings.OverrideWithComparable(i.Scale, other.Scale)
+	i.Upscale = gosettings.OverrideWithPointer(i.Upscale, other.Upscale)
 	i.Codec = gosettings.OverrideWithComparable(i.Codec, other.Codec)
+	i.FallbackCodec = gosettings.OverrideWithComparable(i.FallbackCodec, other.FallbackCodec)
+	i.AlphaCodec = gosettings.OverrideWithComparable(i.AlphaCodec, other.AlphaCodec)
+	i.AlphaOutputExtension = gosettings.OverrideWithComparable(i.AlphaOutputExtension,
+		other.AlphaOutputExtension)
 	i.QScale = gosettings.OverrideWithComparable(i.QScale, other.QScale)
 	i.CRF = gosettings.OverrideWithComparable(i.CRF, other.CRF)
+	i.WebPQuality = gosettings.OverrideWithPointer(i.WebPQuality, other.WebPQuality)
+	i.WebPLossless = gosettings.OverrideWithPointer(i.WebPLossless, other.WebPLossless)
+	i.JXLDistance = gosettings.OverrideWithPointer(i.JXLDistance, other.JXLDistance)
+	i.JXLEffort = gosettings.OverrideWithComparable(i.JXLEffort, other.JXLEffort)
+	i.PNGCompressionLevel = gosettings.OverrideWithComparable(i.PNGCompressionLevel,
+		other.PNGCompressionLevel)
+	i.PNGPalette = gosettings.OverrideWithPointer(i.PNGPalette, other.PNGPalette)
 	i.Skip = gosettings.OverrideWithPointer(i.Skip, other.Skip)
+	i.Workers = gosettings.OverrideWithPointer(i.Workers, other.Workers)
 }
 
 func (i *Image) validate() (err error) {
 	err = validate.AllMatchRegex(i.Extensions, regexExtension)
 	if err != nil {
-		return fmt.Errorf("malformed image file extension: %w", err)
+		return fmt.Errorf("extensions: %w", err)
 	}
 
 	err = validate.MatchRegex(i.OutputExtension, regexExtension)
 	if err != nil {
-		return fmt.Errorf("malformed image output extension: %w", err)
+		return fmt.Errorf("output_extension: %w", err)
 	}
 
 	err = validate.MatchRegex(i.Scale, regexScale)
 	if err != nil {
-		return fmt.Errorf("malformed image scale: %w", err)
+		return fmt.Errorf("scale: %w", err)
 	}
 
-	err = validate.IsOneOf(i.Codec, "mjpeg", "libaom-av1")
+	err = validate.IsOneOf(i.Codec, "mjpeg", "libaom-av1", "libsvtav1",
+		"libwebp", "libjxl", "png")
 	if err != nil {
 		return fmt.Errorf("codec: %w", err)
 	}
 
+	err = validate.IsOneOf(i.AlphaCodec, AlphaCodecNone, "png",
+		"libwebp", "libjxl", "libaom-av1", "libsvtav1")
+	if err != nil {
+		return fmt.Errorf("alpha_codec: %w", err)
+	}
+
+	err = validate.MatchRegex(i.AlphaOutputExtension, regexExtension)
+	if err != nil {
+		return fmt.Errorf("alpha_output_extension: %w", err)
+	}
+
 	const minQScale, maxQScale = 1, 31
 	err = validate.NumberBetween(i.QScale, minQScale, maxQScale)
 	if err != nil {
-		return fmt.Errorf("image qscale value: %w", err)
+		return fmt.Errorf("qscale: %w", err)
+	}
+
+	const maxCRF = 63
+	err = validate.NumberBetween(i.CRF, 0, maxCRF)
+	if err != nil {
+		return fmt.Errorf("crf: %w", err)
+	}
+
+	const maxWebPQuality = 100
+	err = validate.NumberBetween(*i.WebPQuality, 0, maxWebPQuality)
+	if err != nil {
+		return fmt.Errorf("webp_quality: %w", err)
+	}
+
+	const maxJXLDistance = 25
+	err = validate.NumberBetween(*i.JXLDistance, 0, maxJXLDistance)
+	if err != nil {
+		return fmt.Errorf("jxl_distance: %w", err)
+	}
+
+	const minJXLEffort, maxJXLEffort = 1, 9
+	err = validate.NumberBetween(i.JXLEffort, minJXLEffort, maxJXLEffort)
+	if err != nil {
+		return fmt.Errorf("jxl_effort: %w", err)
+	}
+
+	const minPNGCompressionLevel, maxPNGCompressionLevel = 1, 9
+	err = validate.NumberBetween(i.PNGCompressionLevel,
+		minPNGCompressionLevel, maxPNGCompressionLevel)
+	if err != nil {
+		return fmt.Errorf("png_compression_level: %w", err)
+	}
+
+	err = validateWorkers(*i.Workers)
+	if err != nil {
+		return fmt.Errorf("workers: %w", err)
 	}
 
 	return nil
@@ -91,15 +217,137 @@ func (i *Image) toLinesNode() *gotree.Node {
 	node.Appendf("Input file extensions: %s", andStrings(i.Extensions))
 	node.Appendf("Output file extension: %s", i.OutputExtension)
 	node.Appendf("Scale: %s", i.Scale)
-	switch i.Codec {
+	node.Appendf("Upscale: %s", yesno(*i.Upscale))
+	codecNode := node.Appendf("Codec: %s", i.Codec)
+	i.appendCodecNodes(codecNode, i.Codec)
+	if i.FallbackCodec != "" {
+		codecNode.Appendf("Fallback codec: %s", i.FallbackCodec)
+	}
+	if i.AlphaCodec == AlphaCodecNone {
+		node.Appendf("Images with alpha channel: flattened")
+	} else {
+		alphaNode := node.Appendf("Images with alpha channel:")
+		alphaCodecNode := alphaNode.Appendf("Codec: %s", i.AlphaCodec)
+		if i.AlphaCodec != i.Codec {
+			i.appendCodecNodes(alphaCodecNode, i.AlphaCodec)
+		}
+		alphaNode.Appendf("Output file extension: %s", i.AlphaOutputExtension)
+	}
+	node.Appendf("Workers: %d", *i.Workers)
+	return node
+}
+
+// appendCodecNodes appends the settings specific
+// to the codec given to the codec node given.
+func (i *Image) appendCodecNodes(codecNode *gotree.Node, codec string) {
+	switch codec {
 	case "mjpeg":
-		codecNode := node.Appendf("Codec: %s", i.Codec)
 		codecNode.Appendf("Constant quantizer qscale: %d", i.QScale)
-	case "libaom-av1":
-		codecNode := node.Appendf("Codec: libaom-av1")
+	case "libaom-av1", "libsvtav1":
 		codecNode.Appendf("Constant quality CRF: %d", i.CRF)
+	case "libwebp":
+		if *i.WebPLossless {
+			codecNode.Appendf("Lossless: yes")
+		} else {
+			codecNode.Appendf("Quality: %d", *i.WebPQuality)
+		}
+	case "libjxl":
+		codecNode.Appendf("Distance: %g", *i.JXLDistance)
+		codecNode.Appendf("Effort: %d", i.JXLEffort)
+	case "png":
+		codecNode.Appendf("Compression level: %d", i.PNGCompressionLevel)
+		codecNode.Appendf("Palette for images with few colors: %s", yesno(*i.PNGPalette))
+	}
+}
+
+// Fingerprint returns a string made of the settings affecting
+// the conversion of an image file.
+func (i *Image) Fingerprint() string {
+	fingerprint := fmt.Sprintf("ext=%s scale=%s upscale=%t codec=%s qscale=%d crf=%d",
+		i.OutputExtension, i.Scale, *i.Upscale, i.Codec, i.QScale, i.CRF)
+	fingerprint += i.codecFingerprint(i.Codec)
+	fingerprint += fmt.Sprintf(" alpha=%s alphaext=%s", i.AlphaCodec, i.AlphaOutputExtension)
+	if i.AlphaCodec != i.Codec {
+		fingerprint += i.codecFingerprint(i.AlphaCodec)
+	}
+	return fingerprint
+}
+
+// codecFingerprint returns the fingerprint part of
+// the settings specific to the codec given.
+func (i *Image) codecFingerprint(codec string) string {
+	switch codec {
+	case "libwebp":
+		return fmt.Sprintf(" quality=%d lossless=%t", *i.WebPQuality, *i.WebPLossless)
+	case "libjxl":
+		return fmt.Sprintf(" distance=%g effort=%d", *i.JXLDistance, i.JXLEffort)
+	case "png":
+		return fmt.Sprintf(" compression=%d palette=%t", i.PNGCompressionLevel, *i.PNGPalette)
+	default:
+		return ""
+	}
+}
+
+// AlphaCodecNone is the alpha codec value to convert images with
+// an alpha channel like other images, flattening transparent areas.
+const AlphaCodecNone = "none"
+
+// ForAlpha returns a copy of the image settings to use for an image
+// with an alpha channel, where the codec and output extension are
+// the alpha codec and alpha output extension.
+func (i *Image) ForAlpha() (settings Image) {
+	settings = *i
+	if i.AlphaCodec == AlphaCodecNone {
+		return settings
+	}
+	settings.Codec = i.AlphaCodec
+	settings.OutputExtension = i.AlphaOutputExtension
+	settings.FallbackCodec = ""
+	return settings
+}
+
+// fallBack sets the output extension and the alpha codec and alpha
+// output extension following the fallback codec given, if they are
+// the defaults of the current codec. It does not set the codec.
+func (i *Image) fallBack(fallbackCodec string) {
+	if i.OutputExtension == imageCodecExtension(i.Codec) {
+		i.OutputExtension = imageCodecExtension(fallbackCodec)
+	}
+	if i.AlphaCodec == defaultAlphaCodec(i.Codec) {
+		i.AlphaCodec = defaultAlphaCodec(fallbackCodec)
+		if i.AlphaOutputExtension == imageCodecExtension(defaultAlphaCodec(i.Codec)) {
+			i.AlphaOutputExtension = imageCodecExtension(i.AlphaCodec)
+		}
+	}
+}
+
+// defaultAlphaCodec returns the default alpha codec for the image
+// codec given, which is the codec itself if it supports an alpha
+// channel, and `png` otherwise.
+func defaultAlphaCodec(codec string) string {
+	switch codec {
+	case "libwebp", "libjxl", "libaom-av1", "libsvtav1":
+		return codec
+	default:
+		return "png"
+	}
+}
+
+// imageCodecExtension returns the default output file
+// extension for the image codec given.
+func imageCodecExtension(codec string) string {
+	switch codec {
+	case "libaom-av1", "libsvtav1":
+		return ".avif"
+	case "libwebp":
+		return ".webp"
+	case "libjxl":
+		return ".jxl"
+	case "png":
+		return ".png"
+	default:
+		return ".jpg"
 	}
-	return node
 }
 
 func (i *Image) String() string {
@@ -111,12 +359,20 @@ func (i *Image) read(reader *reader.Reader) (err error) {
 	i.OutputExtension = reader.String("IMAGE_OUTPUT_EXTENSION")
 	i.Extensions = reader.CSV("IMAGE_EXTENSIONS")
 
+	i.Upscale, err = reader.BoolPtr("IMAGE_UPSCALE")
+	if err != nil {
+		return err
+	}
+
 	i.Skip, err = reader.BoolPtr("IMAGE_SKIP")
 	if err != nil {
 		return err
 	}
 
 	i.Codec = reader.String("IMAGE_CODEC")
+	i.FallbackCodec = reader.String("IMAGE_FALLBACK_CODEC")
+	i.AlphaCodec = reader.String("IMAGE_ALPHA_CODEC")
+	i.AlphaOutputExtension = reader.String("IMAGE_ALPHA_OUTPUT_EXTENSION")
 	i.CRF, err = reader.Uint("IMAGE_CRF")
 	if err != nil {
 		return err
@@ -127,5 +383,40 @@ func (i *Image) read(reader *reader.Reader) (err error) {
 		return err
 	}
 
+	i.WebPQuality, err = reader.UintPtr("IMAGE_WEBP_QUALITY")
+	if err != nil {
+		return err
+	}
+
+	i.WebPLossless, err = reader.BoolPtr("IMAGE_WEBP_LOSSLESS")
+	if err != nil {
+		return err
+	}
+
+	i.JXLDistance, err = reader.Float64Ptr("IMAGE_JXL_DISTANCE")
+	if err != nil {
+		return err
+	}
+
+	i.JXLEffort, err = reader.Uint("IMAGE_JXL_EFFORT")
+	if err != nil {
+		return err
+	}
+
+	i.PNGCompressionLevel, err = reader.Uint("IMAGE_PNG_COMPRESSION_LEVEL")
+	if err != nil {
+		return err
+	}
+
+	i.PNGPalette, err = reader.BoolPtr("IMAGE_PNG_PALETTE")
+	if err != nil {
+		return err
+	}
+
+	i.Workers, err = reader.UintPtr("IMAGE_WORKERS")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
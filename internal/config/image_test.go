@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Image_setDefaults_webPQuality(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		webPQuality *uint
+		expected    uint
+	}{
+		"unset": {
+			expected: 75,
+		},
+		"zero": {
+			webPQuality: ptrTo(uint(0)),
+			expected:    0,
+		},
+		"set": {
+			webPQuality: ptrTo(uint(40)),
+			expected:    40,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			image := Image{WebPQuality: testCase.webPQuality}
+
+			const defaultWorkers = 1
+			image.setDefaults(defaultWorkers)
+
+			assert.Equal(t, testCase.expected, *image.WebPQuality)
+		})
+	}
+}
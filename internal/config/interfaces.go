@@ -0,0 +1,5 @@
+package config
+
+type DebugLogger interface {
+	Debug(msg string)
+}
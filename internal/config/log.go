@@ -10,7 +10,7 @@ import (
 )
 
 type Log struct {
-	Level string
+	Level string `yaml:"level" json:"level"`
 }
 
 func (l *Log) setDefaults() {
@@ -20,7 +20,7 @@ func (l *Log) setDefaults() {
 func (l *Log) validate() (err error) {
 	_, err = log.ParseLevel(l.Level)
 	if err != nil {
-		return fmt.Errorf("log level: %w", err)
+		return fmt.Errorf("level: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Profile is a named set of coherent video, image and audio
+// settings, which serves as a base for the other settings.
+type Profile struct {
+	Video Video `yaml:"video" json:"video"`
+	Image Image `yaml:"image" json:"image"`
+	Audio Audio `yaml:"audio" json:"audio"`
+}
+
+func builtinProfiles() map[string]Profile {
+	return map[string]Profile{
+		// archive favors quality and keeps the original resolution.
+		"archive": {
+			Video: Video{Scale: "-1:-1", Preset: "6", Crf: ptrTo(uint(25))},
+			Image: Image{Scale: "-1:-1", QScale: 2, CRF: 25,
+				WebPQuality: ptrTo(uint(90)), JXLDistance: ptrTo(0.5)},
+			Audio: Audio{BitRate: ptrTo("128k")},
+		},
+		// web balances quality and size for full HD screens.
+		"web": {
+			Video: Video{Scale: "1920:-1", Preset: "8", Crf: ptrTo(uint(30))},
+			Image: Image{Scale: "1920:-1", QScale: 4, CRF: 32,
+				WebPQuality: ptrTo(uint(80)), JXLDistance: ptrTo(1.0)},
+			Audio: Audio{BitRate: ptrTo("96k")},
+		},
+		// mobile favors size for phone screens.
+		"mobile": {
+			Video: Video{Scale: "1280:-1", Preset: "8", Crf: ptrTo(uint(35))},
+			Image: Image{Scale: "1280:-1", QScale: 6, CRF: 38,
+				WebPQuality: ptrTo(uint(70)), JXLDistance: ptrTo(1.5)},
+			Audio: Audio{BitRate: ptrTo("48k")},
+		},
+		// email minimizes size for attachments.
+		"email": {
+			Video: Video{Scale: "854:-1", Preset: "10", Crf: ptrTo(uint(42))},
+			Image: Image{Scale: "1024:-1", QScale: 10, CRF: 45,
+				WebPQuality: ptrTo(uint(50)), JXLDistance: ptrTo(3.0)},
+			Audio: Audio{BitRate: ptrTo("32k")},
+		},
+	}
+}
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ApplyProfile sets the settings fields unset from the profile
+// settings, if a profile is set. User defined profiles take
+// precedence over built-in profiles with the same name.
+// It must be called before `.SetDefaults()`.
+func (s *Settings) ApplyProfile() (err error) {
+	if s.Profile == "" {
+		return nil
+	}
+
+	profiles := builtinProfiles()
+	for name, profile := range s.Profiles {
+		profiles[name] = profile
+	}
+
+	profile, ok := profiles[s.Profile]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%w: %q must be one of %s",
+			ErrProfileNotFound, s.Profile, orStrings(names))
+	}
+
+	s.Video = overrideVideo(profile.Video, s.Video)
+	s.Image = overrideImage(profile.Image, s.Image)
+	s.Audio = overrideAudio(profile.Audio, s.Audio)
+	return nil
+}
+
+func overrideVideo(base, other Video) Video {
+	base.overrideWith(other)
+	return base
+}
+
+func overrideImage(base, other Image) Image {
+	base.overrideWith(other)
+	return base
+}
+
+func overrideAudio(base, other Audio) Audio {
+	base.overrideWith(other)
+	return base
+}
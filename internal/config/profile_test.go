@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Settings_ApplyProfile(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		settings   Settings
+		expected   Settings
+		errMessage string
+	}{
+		"no profile": {
+			settings: Settings{Video: Video{Scale: "640:-1"}},
+			expected: Settings{Video: Video{Scale: "640:-1"}},
+		},
+		"builtin profile": {
+			settings: Settings{Profile: "mobile"},
+			expected: Settings{
+				Profile: "mobile",
+				Video:   Video{Scale: "1280:-1", Preset: "8", Crf: ptrTo(uint(35))},
+				Image: Image{Scale: "1280:-1", QScale: 6, CRF: 38,
+					WebPQuality: ptrTo(uint(70)), JXLDistance: ptrTo(1.5)},
+				Audio: Audio{BitRate: ptrTo("48k")},
+			},
+		},
+		"builtin profile with overrides": {
+			settings: Settings{
+				Profile: "email",
+				Video:   Video{Crf: ptrTo(uint(30))},
+				Audio:   Audio{Codec: "aac"},
+			},
+			expected: Settings{
+				Profile: "email",
+				Video:   Video{Scale: "854:-1", Preset: "10", Crf: ptrTo(uint(30))},
+				Image: Image{Scale: "1024:-1", QScale: 10, CRF: 45,
+					WebPQuality: ptrTo(uint(50)), JXLDistance: ptrTo(3.0)},
+				Audio: Audio{Codec: "aac", BitRate: ptrTo("32k")},
+			},
+		},
+		"user defined profile overriding builtin profile": {
+			settings: Settings{
+				Profile: "web",
+				Profiles: map[string]Profile{
+					"web": {Image: Image{QScale: 3}},
+				},
+			},
+			expected: Settings{
+				Profile: "web",
+				Profiles: map[string]Profile{
+					"web": {Image: Image{QScale: 3}},
+				},
+				Image: Image{QScale: 3},
+			},
+		},
+		"profile not found": {
+			settings: Settings{
+				Profile:  "tiny",
+				Profiles: map[string]Profile{"small": {}},
+			},
+			errMessage: `profile not found: "tiny" must be one of ` +
+				"archive, email, mobile, small or web",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings := testCase.settings
+			err := settings.ApplyProfile()
+
+			if testCase.errMessage != "" {
+				require.Error(t, err)
+				assert.Equal(t, testCase.errMessage, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, settings)
+		})
+	}
+}
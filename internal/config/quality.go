@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/qdm12/gosettings"
+	"github.com/qdm12/gosettings/reader"
+	"github.com/qdm12/gosettings/validate"
+	"github.com/qdm12/gotree"
+)
+
+const (
+	QualityMetricNone = "none"
+	QualityMetricSSIM = "ssim"
+	QualityMetricPSNR = "psnr"
+	QualityMetricVMAF = "vmaf"
+)
+
+// Quality contains the settings for the quality check of converted
+// images and videos, comparing each output with its scaled input.
+type Quality struct {
+	// Metric is the quality metric to compute, which can be `none`
+	// to disable the quality check, `ssim`, `psnr` or `vmaf`, the
+	// latter requiring an ffmpeg build with libvmaf.
+	// It defaults to `none`.
+	Metric string `yaml:"metric" json:"metric"`
+	// Threshold is the minimum score an output must have to be
+	// accepted. It defaults to 0.95 for SSIM, 35 for PSNR and 90
+	// for VMAF.
+	Threshold *float64 `yaml:"threshold" json:"threshold"`
+	// Retries is the maximum number of times to convert again a file
+	// with a score below the threshold, each time at a higher quality.
+	// If the score is still below the threshold, the output is rejected
+	// and the input file is used instead. It defaults to 2.
+	Retries *uint `yaml:"retries" json:"retries"`
+}
+
+func (q *Quality) setDefaults() {
+	q.Metric = gosettings.DefaultComparable(q.Metric, QualityMetricNone)
+	var defaultThreshold float64
+	switch q.Metric {
+	case QualityMetricSSIM:
+		defaultThreshold = 0.95
+	case QualityMetricPSNR:
+		defaultThreshold = 35
+	case QualityMetricVMAF:
+		defaultThreshold = 90
+	}
+	q.Threshold = gosettings.DefaultPointer(q.Threshold, defaultThreshold)
+	const defaultRetries = 2
+	q.Retries = gosettings.DefaultPointer(q.Retries, defaultRetries)
+}
+
+func (q *Quality) overrideWith(other Quality) {
+	q.Metric = gosettings.OverrideWithComparable(q.Metric, other.Metric)
+	q.Threshold = gosettings.OverrideWithPointer(q.Threshold, other.Threshold)
+	q.Retries = gosettings.OverrideWithPointer(q.Retries, other.Retries)
+}
+
+func (q *Quality) validate() (err error) {
+	err = validate.IsOneOf(q.Metric, QualityMetricNone, QualityMetricSSIM,
+		QualityMetricPSNR, QualityMetricVMAF)
+	if err != nil {
+		return fmt.Errorf("metric: %w", err)
+	}
+
+	if !q.Enabled() {
+		return nil
+	}
+
+	err = validateScore(q.Metric, *q.Threshold)
+	if err != nil {
+		return fmt.Errorf("threshold: %w", err)
+	}
+
+	return nil
+}
+
+var ErrScoreOutOfRange = errors.New("score is out of range")
+
+// validateScore checks the score given is in the range of
+// scores of the quality metric given.
+func validateScore(metric string, score float64) (err error) {
+	var maxScore float64
+	switch metric {
+	case QualityMetricSSIM:
+		maxScore = 1
+	case QualityMetricVMAF:
+		maxScore = 100
+	case QualityMetricPSNR:
+		const maxPSNR = 100
+		maxScore = maxPSNR
+	default:
+		panic(fmt.Sprintf("quality metric %q not implemented", metric))
+	}
+
+	if score < 0 || score > maxScore {
+		return fmt.Errorf("%w: %g must be between 0 and %g for %s",
+			ErrScoreOutOfRange, score, maxScore, metric)
+	}
+	return nil
+}
+
+// Enabled returns true if the quality check is enabled.
+func (q *Quality) Enabled() bool {
+	return q.Metric != QualityMetricNone
+}
+
+func (q *Quality) toLinesNode() *gotree.Node {
+	if !q.Enabled() {
+		return gotree.New("Quality check: disabled")
+	}
+
+	node := gotree.New("Quality check:")
+	node.Appendf("Metric: %s", strings.ToUpper(q.Metric))
+	node.Appendf("Threshold: %g", *q.Threshold)
+	node.Appendf("Retries: %d", *q.Retries)
+	return node
+}
+
+// Fingerprint returns a string made of the quality check settings,
+// which is empty if the quality check is disabled.
+func (q *Quality) Fingerprint() string {
+	if !q.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("quality=%s threshold=%g retries=%d",
+		q.Metric, *q.Threshold, *q.Retries)
+}
+
+func (q *Quality) read(reader *reader.Reader) (err error) {
+	q.Metric = reader.String("QUALITY_METRIC")
+
+	q.Threshold, err = reader.Float64Ptr("QUALITY_THRESHOLD")
+	if err != nil {
+		return err
+	}
+
+	q.Retries, err = reader.UintPtr("QUALITY_RETRIES")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
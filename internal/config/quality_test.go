@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Quality_validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		quality    Quality
+		errWrapped error
+		errMessage string
+	}{
+		"disabled": {},
+		"ssim default threshold": {
+			quality: Quality{Metric: QualityMetricSSIM},
+		},
+		"vmaf default threshold": {
+			quality: Quality{Metric: QualityMetricVMAF},
+		},
+		"ssim threshold out of range": {
+			quality:    Quality{Metric: QualityMetricSSIM, Threshold: ptrTo(95.0)},
+			errWrapped: ErrScoreOutOfRange,
+			errMessage: "threshold: score is out of range: 95 must be between 0 and 1 for ssim",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			quality := testCase.quality
+			quality.setDefaults()
+
+			err := quality.validate()
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/qdm12/gosettings"
+	"github.com/qdm12/gosettings/reader"
+	"github.com/qdm12/gosettings/validate"
+	"github.com/qdm12/gotree"
+)
+
+// Server contains the settings for the HTTP API server,
+// only used with the `serve` command.
+type Server struct {
+	// Address is the listening address of the HTTP server.
+	// It defaults to `:8000`.
+	Address string `yaml:"address" json:"address"`
+	// QueueSize is the maximum number of jobs waiting to be
+	// processed, beyond which new jobs are rejected.
+	// It defaults to 100.
+	QueueSize *uint `yaml:"queue_size" json:"queue_size"`
+	// Workers is the maximum number of jobs to process
+	// concurrently. It defaults to 1.
+	Workers *uint `yaml:"workers" json:"workers"`
+	// MaxUploadMB is the maximum size in megabytes of an
+	// uploaded file. It defaults to 1024.
+	MaxUploadMB *uint `yaml:"max_upload_mb" json:"max_upload_mb"`
+	// JobRetention is the duration a finished job is kept for,
+	// after which it is forgotten and its uploaded file and output
+	// file, for an uploaded file, are removed. It defaults to 1 hour.
+	JobRetention time.Duration `yaml:"job_retention" json:"job_retention"`
+}
+
+func (s *Server) setDefaults() {
+	s.Address = gosettings.DefaultComparable(s.Address, ":8000")
+	const defaultQueueSize = 100
+	s.QueueSize = gosettings.DefaultPointer(s.QueueSize, defaultQueueSize)
+	s.Workers = gosettings.DefaultPointer(s.Workers, 1)
+	const defaultMaxUploadMB = 1024
+	s.MaxUploadMB = gosettings.DefaultPointer(s.MaxUploadMB, defaultMaxUploadMB)
+	s.JobRetention = gosettings.DefaultComparable(s.JobRetention, time.Hour)
+}
+
+func (s *Server) overrideWith(other Server) {
+	s.Address = gosettings.OverrideWithComparable(s.Address, other.Address)
+	s.QueueSize = gosettings.OverrideWithPointer(s.QueueSize, other.QueueSize)
+	s.Workers = gosettings.OverrideWithPointer(s.Workers, other.Workers)
+	s.MaxUploadMB = gosettings.OverrideWithPointer(s.MaxUploadMB, other.MaxUploadMB)
+	s.JobRetention = gosettings.OverrideWithComparable(s.JobRetention, other.JobRetention)
+}
+
+var (
+	ErrQueueSizeZero           = errors.New("queue size cannot be zero")
+	ErrMaxUploadSizeZero       = errors.New("maximum upload size cannot be zero")
+	ErrJobRetentionNotPositive = errors.New("job retention must be positive")
+)
+
+func (s *Server) validate() (err error) {
+	err = validate.ListeningAddress(s.Address, os.Getuid())
+	if err != nil {
+		return fmt.Errorf("address: %w", err)
+	}
+
+	if *s.QueueSize == 0 {
+		return fmt.Errorf("queue_size: %w", ErrQueueSizeZero)
+	}
+
+	err = validateWorkers(*s.Workers)
+	if err != nil {
+		return fmt.Errorf("workers: %w", err)
+	}
+
+	if *s.MaxUploadMB == 0 {
+		return fmt.Errorf("max_upload_mb: %w", ErrMaxUploadSizeZero)
+	}
+
+	if s.JobRetention <= 0 {
+		return fmt.Errorf("job_retention: %w: %s", ErrJobRetentionNotPositive, s.JobRetention)
+	}
+
+	return nil
+}
+
+func (s *Server) toLinesNode() *gotree.Node {
+	node := gotree.New("Server:")
+	node.Appendf("Listening address: %s", s.Address)
+	node.Appendf("Queue size: %d", *s.QueueSize)
+	node.Appendf("Workers: %d", *s.Workers)
+	node.Appendf("Maximum upload size: %dMB", *s.MaxUploadMB)
+	node.Appendf("Job retention: %s", s.JobRetention)
+	return node
+}
+
+func (s *Server) String() string {
+	return s.toLinesNode().String()
+}
+
+func (s *Server) read(reader *reader.Reader) (err error) {
+	s.Address = reader.String("SERVER_ADDRESS")
+
+	s.QueueSize, err = reader.UintPtr("SERVER_QUEUE_SIZE")
+	if err != nil {
+		return err
+	}
+
+	s.Workers, err = reader.UintPtr("SERVER_WORKERS")
+	if err != nil {
+		return err
+	}
+
+	s.MaxUploadMB, err = reader.UintPtr("SERVER_MAX_UPLOAD_MB")
+	if err != nil {
+		return err
+	}
+
+	s.JobRetention, err = reader.Duration("SERVER_JOB_RETENTION")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
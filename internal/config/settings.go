@@ -1,25 +1,84 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/qdm12/gosettings"
 	"github.com/qdm12/gosettings/reader"
+	"github.com/qdm12/gosettings/validate"
 	"github.com/qdm12/gotree"
+	"github.com/qdm12/tinier/internal/models"
 	"github.com/qdm12/tinier/internal/semver"
 )
 
 type Settings struct {
-	InputDirPath     string
-	OutputDirPath    string
-	FfmpegPath       *string
-	FfmpegMinVersion string
-	OverrideOutput   *bool
-	Video            Video
-	Image            Image
-	Audio            Audio
-	Log              Log
+	InputDirPath     string  `yaml:"input_dir_path" json:"input_dir_path"`
+	OutputDirPath    string  `yaml:"output_dir_path" json:"output_dir_path"`
+	FfmpegPath       *string `yaml:"ffmpeg_path" json:"ffmpeg_path"`
+	FfmpegMinVersion string  `yaml:"ffmpeg_min_version" json:"ffmpeg_min_version"`
+	OverrideOutput   *bool   `yaml:"override_output" json:"override_output"`
+	// Resume is whether to skip input files recorded as successfully
+	// processed in the journal file of the output directory, and which
+	// did not change since. It defaults to false.
+	Resume *bool `yaml:"resume" json:"resume"`
+	// InPlace is whether to replace each input file by its converted
+	// file, next to it in the input directory, instead of writing
+	// outputs to the output directory. Files which are not converted
+	// are left untouched, and the journal file is placed in the input
+	// directory. It defaults to false.
+	InPlace *bool `yaml:"in_place" json:"in_place"`
+	// BackupDirPath is the directory path to copy original files to
+	// before replacing them in in-place mode. It defaults to the empty
+	// string, which means original files are not backed up.
+	BackupDirPath *string `yaml:"backup_dir_path" json:"backup_dir_path"`
+	// Watch is whether to keep on running after processing the input
+	// directory, to process new or modified files in the input directory.
+	// It defaults to false.
+	Watch *bool `yaml:"watch" json:"watch"`
+	// WatchStabilityPeriod is the duration a new or modified file size
+	// must remain unchanged for the file to be processed in watch mode.
+	// It defaults to 5 seconds.
+	WatchStabilityPeriod time.Duration `yaml:"watch_stability_period" json:"watch_stability_period"`
+	// DryRun is whether to only print what would be done with each
+	// input file, without converting or copying any file.
+	// It defaults to false.
+	DryRun *bool `yaml:"dry_run" json:"dry_run"`
+	// DryRunJSONPath is the file path to write the dry run plan to,
+	// in the JSON format. It defaults to the empty string, which
+	// means no JSON file is written.
+	DryRunJSONPath *string `yaml:"dry_run_json_path" json:"dry_run_json_path"`
+	// ReportPath is the file path to write the run report to,
+	// in the JSON format. It defaults to the empty string, which
+	// means no report is written.
+	ReportPath *string `yaml:"report" json:"report"`
+	// OutputFormat is the format of the output written to stdout,
+	// which can be `text` for human readable output, or `ndjson`
+	// for a stream of JSON events, one per line.
+	// It defaults to `text`.
+	OutputFormat string `yaml:"output_format" json:"output_format"`
+	// Workers is the maximum number of files to copy concurrently,
+	// and the default for the image and audio workers settings.
+	// It defaults to the number of CPU cores.
+	Workers *uint `yaml:"workers" json:"workers"`
+	// Profile is the name of the profile to use as a base for
+	// the video, image and audio settings. It defaults to the
+	// empty string, which means no profile is used.
+	Profile string `yaml:"profile" json:"profile"`
+	// Profiles are user defined profiles, which can only be set
+	// in the settings file.
+	Profiles map[string]Profile `yaml:"profiles" json:"profiles,omitempty"`
+	Video    Video              `yaml:"video" json:"video"`
+	Image    Image              `yaml:"image" json:"image"`
+	Audio    Audio              `yaml:"audio" json:"audio"`
+	Quality  Quality            `yaml:"quality" json:"quality"`
+	Log      Log                `yaml:"log" json:"log"`
+	Server   Server             `yaml:"server" json:"server"`
 }
 
 // OverrideWith sets fields in the receiving settings
@@ -30,10 +89,26 @@ func (s *Settings) OverrideWith(other Settings) {
 	s.FfmpegPath = gosettings.OverrideWithPointer(s.FfmpegPath, other.FfmpegPath)
 	s.FfmpegMinVersion = gosettings.OverrideWithComparable(s.FfmpegMinVersion, other.FfmpegMinVersion)
 	s.OverrideOutput = gosettings.OverrideWithPointer(s.OverrideOutput, other.OverrideOutput)
+	s.Resume = gosettings.OverrideWithPointer(s.Resume, other.Resume)
+	s.InPlace = gosettings.OverrideWithPointer(s.InPlace, other.InPlace)
+	s.BackupDirPath = gosettings.OverrideWithPointer(s.BackupDirPath, other.BackupDirPath)
+	s.Watch = gosettings.OverrideWithPointer(s.Watch, other.Watch)
+	s.DryRun = gosettings.OverrideWithPointer(s.DryRun, other.DryRun)
+	s.DryRunJSONPath = gosettings.OverrideWithPointer(s.DryRunJSONPath, other.DryRunJSONPath)
+	s.ReportPath = gosettings.OverrideWithPointer(s.ReportPath, other.ReportPath)
+	s.OutputFormat = gosettings.OverrideWithComparable(s.OutputFormat, other.OutputFormat)
+	s.WatchStabilityPeriod = gosettings.OverrideWithComparable(s.WatchStabilityPeriod, other.WatchStabilityPeriod)
+	s.Workers = gosettings.OverrideWithPointer(s.Workers, other.Workers)
+	s.Profile = gosettings.OverrideWithComparable(s.Profile, other.Profile)
+	if other.Profiles != nil {
+		s.Profiles = other.Profiles
+	}
 	s.Video.overrideWith(other.Video)
 	s.Image.overrideWith(other.Image)
 	s.Audio.overrideWith(other.Audio)
+	s.Quality.overrideWith(other.Quality)
 	s.Log.overrideWith(other.Log)
+	s.Server.overrideWith(other.Server)
 }
 
 // SetDefaults sets the defaults to all the zero-ed fields
@@ -44,66 +119,222 @@ func (s *Settings) SetDefaults() {
 	s.FfmpegPath = gosettings.DefaultPointer(s.FfmpegPath, "")
 	s.FfmpegMinVersion = gosettings.DefaultComparable(s.FfmpegMinVersion, "5.0.1")
 	s.OverrideOutput = gosettings.DefaultPointer(s.OverrideOutput, false)
+	s.Resume = gosettings.DefaultPointer(s.Resume, false)
+	s.InPlace = gosettings.DefaultPointer(s.InPlace, false)
+	s.BackupDirPath = gosettings.DefaultPointer(s.BackupDirPath, "")
+	s.Watch = gosettings.DefaultPointer(s.Watch, false)
+	s.DryRun = gosettings.DefaultPointer(s.DryRun, false)
+	s.DryRunJSONPath = gosettings.DefaultPointer(s.DryRunJSONPath, "")
+	s.ReportPath = gosettings.DefaultPointer(s.ReportPath, "")
+	s.OutputFormat = gosettings.DefaultComparable(s.OutputFormat, OutputFormatText)
+	const defaultWatchStabilityPeriod = 5 * time.Second
+	s.WatchStabilityPeriod = gosettings.DefaultComparable(s.WatchStabilityPeriod, defaultWatchStabilityPeriod)
+	s.Workers = gosettings.DefaultPointer(s.Workers, uint(runtime.NumCPU()))
 	s.Video.setDefaults()
-	s.Image.setDefaults()
-	s.Audio.setDefaults()
+	s.Image.setDefaults(*s.Workers)
+	s.Audio.setDefaults(*s.Workers)
+	s.Quality.setDefaults()
 	s.Log.setDefaults()
+	s.Server.setDefaults()
 }
 
-// Validate validates all the settings are correct.
+const (
+	OutputFormatText   = "text"
+	OutputFormatNDJSON = "ndjson"
+)
+
+var (
+	ErrWatchStabilityPeriodNegative = errors.New("watch stability period cannot be negative")
+	ErrBackupDirWithoutInPlace      = errors.New("backup directory can only be set in in-place mode")
+	ErrBackupDirInInputDir          = errors.New("backup directory cannot be in the input directory")
+	ErrInPlaceWatch                 = errors.New("in-place mode cannot be used with watch mode")
+)
+
+// Validate validates all the settings are correct. Errors start with
+// the settings file key of the invalid field, such as image.qscale.
 // Note `.SetDefaults()` must be called to ensure all
 // the fields are not their zeroed value such as `nil`.
 func (s *Settings) Validate() (err error) {
 	_, err = os.Stat(s.InputDirPath)
 	if err != nil {
-		return fmt.Errorf("input directory: %w", err)
+		return fmt.Errorf("input_dir_path: %w", err)
 	}
 
 	if *s.FfmpegPath != "" {
 		_, err = os.Stat(*s.FfmpegPath)
 		if err != nil {
-			return fmt.Errorf("ffmpeg path: %w", err)
+			return fmt.Errorf("ffmpeg_path: %w", err)
 		}
 	}
 
 	if s.FfmpegMinVersion != "" {
 		_, err = semver.Parse(s.FfmpegMinVersion)
 		if err != nil {
-			return fmt.Errorf("minimum ffmpeg version: %w", err)
+			return fmt.Errorf("ffmpeg_min_version: %w", err)
 		}
 	}
 
+	if s.WatchStabilityPeriod < 0 {
+		return fmt.Errorf("watch_stability_period: %w: %s", ErrWatchStabilityPeriodNegative, s.WatchStabilityPeriod)
+	}
+
+	err = s.validateInPlace()
+	if err != nil {
+		return err
+	}
+
+	err = validate.IsOneOf(s.OutputFormat, OutputFormatText, OutputFormatNDJSON)
+	if err != nil {
+		return fmt.Errorf("output_format: %w", err)
+	}
+
+	err = validateWorkers(*s.Workers)
+	if err != nil {
+		return fmt.Errorf("workers: %w", err)
+	}
+
 	mapping := map[string]func() (err error){
-		"video": s.Video.validate,
-		"image": s.Image.validate,
-		"audio": s.Audio.validate,
-		"log":   s.Log.validate,
+		"video":   s.Video.validate,
+		"image":   s.Image.validate,
+		"audio":   s.Audio.validate,
+		"quality": s.Quality.validate,
+		"log":     s.Log.validate,
+		"server":  s.Server.validate,
 	}
 
 	for name, validate := range mapping {
 		err = validate()
 		if err != nil {
-			return fmt.Errorf("%s settings: %w", name, err)
+			return fmt.Errorf("%s.%w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Settings) validateInPlace() (err error) {
+	if !*s.InPlace {
+		if *s.BackupDirPath != "" {
+			return fmt.Errorf("backup_dir_path: %w", ErrBackupDirWithoutInPlace)
 		}
+		return nil
+	}
+
+	if *s.Watch {
+		// Temporary files written next to the input files
+		// would be picked up by the watcher.
+		return fmt.Errorf("in_place: %w", ErrInPlaceWatch)
+	}
+
+	if *s.BackupDirPath == "" {
+		return nil
+	}
+
+	absoluteInputDir, err := filepath.Abs(s.InputDirPath)
+	if err != nil {
+		return fmt.Errorf("input_dir_path: getting absolute path: %w", err)
+	}
+	absoluteBackupDir, err := filepath.Abs(*s.BackupDirPath)
+	if err != nil {
+		return fmt.Errorf("backup_dir_path: getting absolute path: %w", err)
 	}
 
+	relativePath, err := filepath.Rel(absoluteInputDir, absoluteBackupDir)
+	if err == nil && relativePath != ".." &&
+		!strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("backup_dir_path: %w: %s", ErrBackupDirInInputDir, *s.BackupDirPath)
+	}
 	return nil
 }
 
+// Fingerprint returns a string made of the settings affecting
+// the processing of a file of the given media type.
+func (s *Settings) Fingerprint(mediaType models.MediaType) string {
+	switch mediaType {
+	case models.MediaTypeImage:
+		return joinFingerprints(s.Image.Fingerprint(), s.Quality.Fingerprint())
+	case models.MediaTypeAudio:
+		return s.Audio.Fingerprint()
+	case models.MediaTypeVideo:
+		return joinFingerprints(s.Video.Fingerprint(), s.Quality.Fingerprint())
+	case models.MediaTypeOther:
+		return ""
+	default:
+		panic(fmt.Sprintf("media type %q not implemented", mediaType))
+	}
+}
+
+// joinFingerprints joins the non empty fingerprints given, such that
+// adding an empty fingerprint does not change the resulting fingerprint.
+func joinFingerprints(fingerprints ...string) string {
+	nonEmpty := make([]string, 0, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		if fingerprint != "" {
+			nonEmpty = append(nonEmpty, fingerprint)
+		}
+	}
+	return strings.Join(nonEmpty, " ")
+}
+
+// mediaTypeString returns the settings string relevant
+// to the media type given.
+func (s *Settings) mediaTypeString(mediaType models.MediaType) string {
+	switch mediaType {
+	case models.MediaTypeImage:
+		return s.Image.String()
+	case models.MediaTypeAudio:
+		return s.Audio.String()
+	case models.MediaTypeVideo:
+		return s.Video.String()
+	default:
+		return s.String()
+	}
+}
+
 // toLinesNode returns a gotree.Node with the settings
-// as a formatted tree node.
+// as a formatted tree node. The server settings are not
+// included since they are only used by the serve command.
 func (s *Settings) toLinesNode() *gotree.Node {
 	node := gotree.New("Settings:")
 	node.Appendf("Input directory: %s", s.InputDirPath)
-	node.Appendf("Output directory: %s", s.OutputDirPath)
+	if *s.InPlace {
+		inPlaceNode := node.Appendf("In-place mode: yes")
+		if *s.BackupDirPath != "" {
+			inPlaceNode.Appendf("Backup directory: %s", *s.BackupDirPath)
+		}
+	} else {
+		node.Appendf("Output directory: %s", s.OutputDirPath)
+	}
 	if *s.FfmpegPath != "" {
 		node.Appendf("FFMPEG path: %s", *s.FfmpegPath)
 	}
 	node.Appendf("FFMPEG minimum version: %s", s.FfmpegMinVersion)
 	node.Appendf("Override existing output: %s", yesno(*s.OverrideOutput))
+	node.Appendf("Resume from journal: %s", yesno(*s.Resume))
+	if *s.DryRun {
+		dryRunNode := node.Appendf("Dry run: yes")
+		if *s.DryRunJSONPath != "" {
+			dryRunNode.Appendf("JSON plan file path: %s", *s.DryRunJSONPath)
+		}
+	}
+	if *s.ReportPath != "" {
+		node.Appendf("Report file path: %s", *s.ReportPath)
+	}
+	node.Appendf("Output format: %s", s.OutputFormat)
+	if *s.Watch {
+		watchNode := node.Appendf("Watch input directory: yes")
+		watchNode.Appendf("Stability period: %s", s.WatchStabilityPeriod)
+	} else {
+		node.Appendf("Watch input directory: no")
+	}
+	node.Appendf("Workers: %d", *s.Workers)
+	if s.Profile != "" {
+		node.Appendf("Profile: %s", s.Profile)
+	}
 	node.AppendNode(s.Video.toLinesNode())
 	node.AppendNode(s.Image.toLinesNode())
 	node.AppendNode(s.Audio.toLinesNode())
+	node.AppendNode(s.Quality.toLinesNode())
 	node.AppendNode(s.Log.toLinesNode())
 	return node
 }
@@ -123,6 +354,44 @@ func (s *Settings) Read(reader *reader.Reader) (err error) {
 		return err
 	}
 
+	s.Resume, err = reader.BoolPtr("RESUME")
+	if err != nil {
+		return err
+	}
+
+	s.InPlace, err = reader.BoolPtr("IN_PLACE")
+	if err != nil {
+		return err
+	}
+
+	s.BackupDirPath = reader.Get("BACKUP_DIR_PATH", keepCase())
+
+	s.DryRun, err = reader.BoolPtr("DRY_RUN")
+	if err != nil {
+		return err
+	}
+
+	s.DryRunJSONPath = reader.Get("DRY_RUN_JSON_PATH", keepCase())
+	s.ReportPath = reader.Get("REPORT", keepCase())
+	s.OutputFormat = reader.String("OUTPUT_FORMAT")
+
+	s.Watch, err = reader.BoolPtr("WATCH")
+	if err != nil {
+		return err
+	}
+
+	s.WatchStabilityPeriod, err = reader.Duration("WATCH_STABILITY_PERIOD")
+	if err != nil {
+		return err
+	}
+
+	s.Workers, err = reader.UintPtr("WORKERS")
+	if err != nil {
+		return err
+	}
+
+	s.Profile = reader.String("PROFILE")
+
 	err = s.Image.read(reader)
 	if err != nil {
 		return fmt.Errorf("image settings: %w", err)
@@ -138,6 +407,17 @@ func (s *Settings) Read(reader *reader.Reader) (err error) {
 		return fmt.Errorf("audio settings: %w", err)
 	}
 
+	err = s.Quality.read(reader)
+	if err != nil {
+		return fmt.Errorf("quality settings: %w", err)
+	}
+
 	s.Log.read(reader)
+
+	err = s.Server.read(reader)
+	if err != nil {
+		return fmt.Errorf("server settings: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Settings_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		yaml       string
+		errMessage string
+	}{
+		"valid": {
+			yaml: "image:\n  qscale: 3\n",
+		},
+		"top level key": {
+			yaml:       "output_format: xml\n",
+			errMessage: "output_format: value is not one of the possible choices: xml must be one of text or ndjson",
+		},
+		"section key": {
+			yaml:       "image:\n  qscale: 40\n",
+			errMessage: "image.qscale: value is out of bounds: 40 must be between 1 and 31 included",
+		},
+		"section sentinel error": {
+			yaml:       "server:\n  queue_size: 0\n",
+			errMessage: "server.queue_size: queue size cannot be zero",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings, err := decodeYAML(strings.NewReader(testCase.yaml))
+			require.NoError(t, err)
+			settings.InputDirPath = t.TempDir()
+			settings.SetDefaults()
+
+			err = settings.Validate()
+
+			if testCase.errMessage == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
+
+func Test_Settings_validateInPlace(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+
+	testCases := map[string]struct {
+		settings   Settings
+		errWrapped error
+	}{
+		"in-place disabled": {},
+		"backup directory without in-place": {
+			settings:   Settings{BackupDirPath: ptrTo("backup")},
+			errWrapped: ErrBackupDirWithoutInPlace,
+		},
+		"in-place with watch": {
+			settings:   Settings{InPlace: ptrTo(true), Watch: ptrTo(true)},
+			errWrapped: ErrInPlaceWatch,
+		},
+		"backup directory in input directory": {
+			settings: Settings{
+				InPlace:       ptrTo(true),
+				BackupDirPath: ptrTo(filepath.Join(inputDir, "backup")),
+			},
+			errWrapped: ErrBackupDirInInputDir,
+		},
+		"backup directory next to input directory": {
+			settings: Settings{
+				InPlace:       ptrTo(true),
+				BackupDirPath: ptrTo(inputDir + "-backup"),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings := testCase.settings
+			settings.InputDirPath = inputDir
+			settings.SetDefaults()
+
+			err := settings.validateInPlace()
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -8,58 +9,128 @@ import (
 	"github.com/qdm12/gosettings/reader"
 	"github.com/qdm12/gosettings/validate"
 	"github.com/qdm12/gotree"
+	"github.com/qdm12/tinier/internal/size"
 )
 
 type Video struct {
 	// Extensions is the list of video file extensions to convert
 	// from the input directory. Videos with file extensions not
 	// listed are simply copied to the output directory.
-	Extensions []string
+	Extensions []string `yaml:"extensions" json:"extensions"`
 	// OutputExtension is the output extension to set on converted
 	// video files. If defaults to `.mp4`.
-	OutputExtension string
-	Scale           string
-	Preset          string
-	Codec           string
-	Crf             *uint
-	Skip            *bool
+	OutputExtension string `yaml:"output_extension" json:"output_extension"`
+	Scale           string `yaml:"scale" json:"scale"`
+	// Upscale allows upscaling videos smaller than the scale
+	// resolution. It defaults to false, so videos are only
+	// ever downscaled.
+	Upscale *bool  `yaml:"upscale" json:"upscale"`
+	Preset  string `yaml:"preset" json:"preset"`
+	Codec   string `yaml:"codec" json:"codec"`
+	// FallbackCodec is the codec to use instead of Codec if the ffmpeg
+	// used is not built with the Codec encoder. It defaults to the empty
+	// string, in which case tinier fails at start if Codec is unavailable.
+	FallbackCodec string `yaml:"fallback_codec" json:"fallback_codec"`
+	Crf           *uint  `yaml:"crf" json:"crf"`
+	Skip          *bool  `yaml:"skip" json:"skip"`
+	// TinyBitsPerPixel is the maximum bits per pixel per frame of
+	// a video already encoded with the codec for it to be considered
+	// already tiny and copied as is instead of being converted.
+	// It defaults to 0.1 and can be set to 0 to disable it.
+	TinyBitsPerPixel *float64 `yaml:"tiny_bits_per_pixel" json:"tiny_bits_per_pixel"`
+	// TargetQuality is the quality score each video must reach, in
+	// which case the CRF is searched for each video instead of using
+	// the Crf field value. The CRF search encodes sample segments of
+	// the video at several CRF values, and picks the highest CRF for
+	// which the samples mean score is at least the target quality.
+	// It defaults to 0, which disables the CRF search.
+	TargetQuality *float64 `yaml:"target_quality" json:"target_quality"`
+	// TargetMetric is the quality metric for the target quality,
+	// which can be `ssim`, `psnr` or `vmaf`. It defaults to `ssim`.
+	TargetMetric string `yaml:"target_metric" json:"target_metric"`
+	// TargetSamples is the number of sample segments to encode for
+	// each CRF value tried. It defaults to 3.
+	TargetSamples *uint `yaml:"target_samples" json:"target_samples"`
+	// TargetSize is the maximum size of each converted video, such as
+	// `25MB`, in which case the video bit rate is computed from the
+	// video duration and the audio bit rate, and the video is encoded
+	// in two passes if the codec supports it, instead of using the Crf
+	// field value. It defaults to the empty string, which disables it.
+	TargetSize *string `yaml:"target_size" json:"target_size"`
+	// TargetBitRate is the overall bit rate of each converted video,
+	// such as `2M`, in which case the video bit rate is the target bit
+	// rate minus the audio bit rate, and the video is encoded in two
+	// passes if the codec supports it, instead of using the Crf field
+	// value. It defaults to the empty string, which disables it.
+	TargetBitRate *string `yaml:"target_bitrate" json:"target_bitrate"`
+	// Workers is the maximum number of video files to process
+	// concurrently. It defaults to 1 since video encoders already
+	// use all the CPU cores available.
+	Workers *uint `yaml:"workers" json:"workers"`
 }
 
 func (v *Video) setDefaults() {
 	v.Extensions = gosettings.DefaultSlice(v.Extensions, []string{".mp4", ".mov", ".avi"})
 	v.OutputExtension = gosettings.DefaultComparable(v.OutputExtension, ".mp4")
 	v.Scale = gosettings.DefaultComparable(v.Scale, "1280:-1")
+	v.Upscale = gosettings.DefaultPointer(v.Upscale, false)
 	v.Preset = gosettings.DefaultComparable(v.Preset, "8")
 	v.Codec = gosettings.DefaultComparable(v.Codec, "libsvtav1")
 	const defaultCRF = 23
 	v.Crf = gosettings.DefaultPointer(v.Crf, defaultCRF)
 	v.Skip = gosettings.DefaultPointer(v.Skip, false)
+	const defaultTinyBitsPerPixel = 0.1
+	v.TinyBitsPerPixel = gosettings.DefaultPointer(v.TinyBitsPerPixel, defaultTinyBitsPerPixel)
+	v.TargetQuality = gosettings.DefaultPointer(v.TargetQuality, 0)
+	v.TargetMetric = gosettings.DefaultComparable(v.TargetMetric, QualityMetricSSIM)
+	const defaultTargetSamples = 3
+	v.TargetSamples = gosettings.DefaultPointer(v.TargetSamples, defaultTargetSamples)
+	v.TargetSize = gosettings.DefaultPointer(v.TargetSize, "")
+	v.TargetBitRate = gosettings.DefaultPointer(v.TargetBitRate, "")
+	v.Workers = gosettings.DefaultPointer(v.Workers, 1)
 }
 
 func (v *Video) overrideWith(other Video) {
 	v.Extensions = gosettings.OverrideWithSlice(v.Extensions, other.Extensions)
 	v.OutputExtension = gosettings.OverrideWithComparable(v.OutputExtension, other.OutputExtension)
 	v.Scale = gosettings.OverrideWithComparable(v.Scale, other.Scale)
+	v.Upscale = gosettings.OverrideWithPointer(v.Upscale, other.Upscale)
 	v.Preset = gosettings.OverrideWithComparable(v.Preset, other.Preset)
 	v.Codec = gosettings.OverrideWithComparable(v.Codec, other.Codec)
+	v.FallbackCodec = gosettings.OverrideWithComparable(v.FallbackCodec, other.FallbackCodec)
 	v.Crf = gosettings.OverrideWithPointer(v.Crf, other.Crf)
 	v.Skip = gosettings.OverrideWithPointer(v.Skip, other.Skip)
+	v.TinyBitsPerPixel = gosettings.OverrideWithPointer(v.TinyBitsPerPixel, other.TinyBitsPerPixel)
+	v.TargetQuality = gosettings.OverrideWithPointer(v.TargetQuality, other.TargetQuality)
+	v.TargetMetric = gosettings.OverrideWithComparable(v.TargetMetric, other.TargetMetric)
+	v.TargetSamples = gosettings.OverrideWithPointer(v.TargetSamples, other.TargetSamples)
+	v.TargetSize = gosettings.OverrideWithPointer(v.TargetSize, other.TargetSize)
+	v.TargetBitRate = gosettings.OverrideWithPointer(v.TargetBitRate, other.TargetBitRate)
+	v.Workers = gosettings.OverrideWithPointer(v.Workers, other.Workers)
 }
 
+var (
+	ErrTinyThresholdNegative = errors.New("already tiny threshold cannot be negative")
+	ErrTargetSamplesZero     = errors.New("target quality samples cannot be zero")
+	ErrTargetSizeAndBitRate  = errors.New("target size and target bit rate cannot be both set")
+	ErrTargetSizeAndQuality  = errors.New("target size or bit rate cannot be set with a target quality")
+	ErrTargetSizeCodec       = errors.New("codec does not support a target size or bit rate")
+)
+
 func (v *Video) validate() (err error) {
 	err = validate.AllMatchRegex(v.Extensions, regexExtension)
 	if err != nil {
-		return fmt.Errorf("malformed video file extension: %w", err)
+		return fmt.Errorf("extensions: %w", err)
 	}
 
 	err = validate.MatchRegex(v.OutputExtension, regexExtension)
 	if err != nil {
-		return fmt.Errorf("malformed video output extension: %w", err)
+		return fmt.Errorf("output_extension: %w", err)
 	}
 
 	err = validate.MatchRegex(v.Scale, regexScale)
 	if err != nil {
-		return fmt.Errorf("malformed video scale: %w", err)
+		return fmt.Errorf("scale: %w", err)
 	}
 
 	var validPresets []string
@@ -73,14 +144,79 @@ func (v *Video) validate() (err error) {
 	if len(validPresets) > 0 {
 		err = validate.IsOneOf(v.Preset, validPresets...)
 		if err != nil {
-			return fmt.Errorf("preset is unknown for codec %s: %w", v.Codec, err)
+			return fmt.Errorf("preset: unknown for codec %s: %w", v.Codec, err)
 		}
 	}
 
 	const minCRF, maxCRF = 0, 51
 	err = validate.NumberBetween(*v.Crf, minCRF, maxCRF)
 	if err != nil {
-		return fmt.Errorf("video CRF: %w", err)
+		return fmt.Errorf("crf: %w", err)
+	}
+
+	if *v.TinyBitsPerPixel < 0 {
+		return fmt.Errorf("tiny_bits_per_pixel: %w: %g",
+			ErrTinyThresholdNegative, *v.TinyBitsPerPixel)
+	}
+
+	err = validate.IsOneOf(v.TargetMetric, QualityMetricSSIM,
+		QualityMetricPSNR, QualityMetricVMAF)
+	if err != nil {
+		return fmt.Errorf("target_metric: %w", err)
+	}
+
+	if v.CRFSearch() {
+		err = validateScore(v.TargetMetric, *v.TargetQuality)
+		if err != nil {
+			return fmt.Errorf("target_quality: %w", err)
+		}
+
+		if *v.TargetSamples == 0 {
+			return fmt.Errorf("target_samples: %w", ErrTargetSamplesZero)
+		}
+	}
+
+	err = v.validateTargetSize()
+	if err != nil {
+		return err
+	}
+
+	err = validateWorkers(*v.Workers)
+	if err != nil {
+		return fmt.Errorf("workers: %w", err)
+	}
+
+	return nil
+}
+
+func (v *Video) validateTargetSize() (err error) {
+	if !v.TargetSizeMode() {
+		return nil
+	}
+
+	if *v.TargetSize != "" && *v.TargetBitRate != "" {
+		return fmt.Errorf("target_size: %w", ErrTargetSizeAndBitRate)
+	} else if v.CRFSearch() {
+		return fmt.Errorf("target_quality: %w", ErrTargetSizeAndQuality)
+	}
+
+	if *v.TargetSize != "" {
+		_, err = size.Parse(*v.TargetSize)
+		if err != nil {
+			return fmt.Errorf("target_size: %w", err)
+		}
+	} else {
+		_, err = size.ParseBitRate(*v.TargetBitRate)
+		if err != nil {
+			return fmt.Errorf("target_bitrate: %w", err)
+		}
+	}
+
+	switch strings.ToLower(v.Codec) {
+	case "libx264", "libx265", "libvpx", "libvpx-vp9", "libaom-av1": // two passes
+	case "libsvtav1": // single pass, since ffmpeg does not support its two passes
+	default:
+		return fmt.Errorf("codec: %w: %s", ErrTargetSizeCodec, v.Codec)
 	}
 
 	return nil
@@ -95,12 +231,62 @@ func (v *Video) toLinesNode() *gotree.Node {
 	node.Appendf("Input file extensions: %s", andStrings(v.Extensions))
 	node.Appendf("Output file extension: %s", v.OutputExtension)
 	node.Appendf("Scale: %s", v.Scale)
+	node.Appendf("Upscale: %s", yesno(*v.Upscale))
 	node.Appendf("Preset: %s", v.Preset)
-	node.Appendf("Codec: %s", v.Codec)
-	node.Appendf("Constant rate factor: %d", *v.Crf)
+	codecNode := node.Appendf("Codec: %s", v.Codec)
+	if v.FallbackCodec != "" {
+		codecNode.Appendf("Fallback codec: %s", v.FallbackCodec)
+	}
+	switch {
+	case v.CRFSearch():
+		targetNode := node.Appendf("Target quality: %s %g",
+			strings.ToUpper(v.TargetMetric), *v.TargetQuality)
+		targetNode.Appendf("Samples: %d", *v.TargetSamples)
+	case *v.TargetSize != "":
+		node.Appendf("Target size: %s", *v.TargetSize)
+	case *v.TargetBitRate != "":
+		node.Appendf("Target bit rate: %s", *v.TargetBitRate)
+	default:
+		node.Appendf("Constant rate factor: %d", *v.Crf)
+	}
+	if *v.TinyBitsPerPixel == 0 {
+		node.Appendf("Already tiny threshold: disabled")
+	} else {
+		node.Appendf("Already tiny threshold: %g bits per pixel", *v.TinyBitsPerPixel)
+	}
+	node.Appendf("Workers: %d", *v.Workers)
 	return node
 }
 
+// TargetSizeMode returns true if the video bit rate is computed
+// from the target size or target bit rate.
+func (v *Video) TargetSizeMode() bool {
+	return *v.TargetSize != "" || *v.TargetBitRate != ""
+}
+
+// CRFSearch returns true if the CRF is searched for each
+// video to reach the target quality.
+func (v *Video) CRFSearch() bool {
+	return *v.TargetQuality > 0
+}
+
+// Fingerprint returns a string made of the settings affecting
+// the conversion of a video file.
+func (v *Video) Fingerprint() string {
+	fingerprint := fmt.Sprintf("ext=%s scale=%s upscale=%t preset=%s codec=%s crf=%d tinybpp=%g",
+		v.OutputExtension, v.Scale, *v.Upscale, v.Preset, v.Codec, *v.Crf,
+		*v.TinyBitsPerPixel)
+	if v.CRFSearch() {
+		fingerprint += fmt.Sprintf(" target=%s:%g samples=%d",
+			v.TargetMetric, *v.TargetQuality, *v.TargetSamples)
+	}
+	if v.TargetSizeMode() {
+		fingerprint += fmt.Sprintf(" targetsize=%s targetbitrate=%s",
+			*v.TargetSize, *v.TargetBitRate)
+	}
+	return fingerprint
+}
+
 func (v *Video) String() string {
 	return v.toLinesNode().String()
 }
@@ -111,6 +297,12 @@ func (v *Video) read(reader *reader.Reader) (err error) {
 	v.Scale = reader.String("VIDEO_SCALE")
 	v.Preset = reader.String("VIDEO_PRESET")
 	v.Codec = reader.String("VIDEO_CODEC")
+	v.FallbackCodec = reader.String("VIDEO_FALLBACK_CODEC")
+
+	v.Upscale, err = reader.BoolPtr("VIDEO_UPSCALE")
+	if err != nil {
+		return err
+	}
 
 	v.Crf, err = reader.UintPtr("VIDEO_CRF")
 	if err != nil {
@@ -122,5 +314,30 @@ func (v *Video) read(reader *reader.Reader) (err error) {
 		return err
 	}
 
+	v.TinyBitsPerPixel, err = reader.Float64Ptr("VIDEO_TINY_BITS_PER_PIXEL")
+	if err != nil {
+		return err
+	}
+
+	v.TargetQuality, err = reader.Float64Ptr("VIDEO_TARGET_QUALITY")
+	if err != nil {
+		return err
+	}
+
+	v.TargetMetric = reader.String("VIDEO_TARGET_METRIC")
+
+	v.TargetSamples, err = reader.UintPtr("VIDEO_TARGET_SAMPLES")
+	if err != nil {
+		return err
+	}
+
+	v.TargetSize = reader.Get("VIDEO_TARGET_SIZE")
+	v.TargetBitRate = reader.Get("VIDEO_TARGET_BITRATE")
+
+	v.Workers, err = reader.UintPtr("VIDEO_WORKERS")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/qdm12/tinier/internal/size"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Video_validateTargetSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		video      Video
+		errWrapped error
+		errMessage string
+	}{
+		"disabled": {},
+		"target size with two pass codec": {
+			video: Video{Codec: "libx265", TargetSize: ptrTo("25MB")},
+		},
+		"target bit rate with single pass codec": {
+			video: Video{Codec: "libsvtav1", TargetBitRate: ptrTo("2M")},
+		},
+		"target size and bit rate": {
+			video:      Video{TargetSize: ptrTo("25MB"), TargetBitRate: ptrTo("2M")},
+			errWrapped: ErrTargetSizeAndBitRate,
+			errMessage: "target_size: target size and target bit rate cannot be both set",
+		},
+		"target size with target quality": {
+			video:      Video{TargetSize: ptrTo("25MB"), TargetQuality: ptrTo(0.95)},
+			errWrapped: ErrTargetSizeAndQuality,
+			errMessage: "target_quality: target size or bit rate cannot be set with a target quality",
+		},
+		"malformed target size": {
+			video:      Video{TargetSize: ptrTo("25XB")},
+			errWrapped: size.ErrSizeMalformed,
+			errMessage: "target_size: size is malformed: 25XB",
+		},
+		"unsupported codec": {
+			video:      Video{Codec: "mpeg4", TargetBitRate: ptrTo("2M")},
+			errWrapped: ErrTargetSizeCodec,
+			errMessage: "codec: codec does not support a target size or bit rate: mpeg4",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			video := testCase.video
+			video.setDefaults()
+
+			err := video.validateTargetSize()
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package crfsearch searches the highest CRF value for which encoded
+// sample segments of a video meet a target quality score.
+package crfsearch
+
+import (
+	"context"
+	"time"
+)
+
+// Sample is a segment of a video.
+type Sample struct {
+	Start    time.Duration
+	Duration time.Duration
+}
+
+// Samples returns count samples of the duration given spread evenly
+// across a video of the total duration given. It returns a single
+// sample covering the whole video if the samples would cover the
+// whole video or if the total duration is unknown.
+func Samples(total time.Duration, count uint, duration time.Duration) (samples []Sample) {
+	if total == 0 || time.Duration(count)*duration >= total {
+		return []Sample{{Duration: total}}
+	}
+
+	samples = make([]Sample, count)
+	for i := range samples {
+		// Center each sample in its slice of the video
+		// to avoid sampling intros and outros.
+		center := total * time.Duration(2*i+1) / time.Duration(2*count) //nolint:gomnd
+		samples[i] = Sample{
+			Start:    center - duration/2, //nolint:gomnd
+			Duration: duration,
+		}
+	}
+	return samples
+}
+
+// Score returns the quality score for the CRF value given.
+type Score func(ctx context.Context, crf uint) (score float64, err error)
+
+// Search returns the highest CRF value in the range [minCRF, maxCRF] for
+// which the score is at least the target score given, together with its
+// score, assuming the score decreases as the CRF increases. It returns
+// ok as false if the score of minCRF is below the target, in which case
+// crf is minCRF.
+func Search(ctx context.Context, minCRF, maxCRF uint, target float64,
+	score Score) (crf uint, crfScore float64, ok bool, err error) {
+	low, high := minCRF, maxCRF
+	for low <= high {
+		middle := low + (high-low)/2 //nolint:gomnd
+		middleScore, err := score(ctx, middle)
+		if err != nil {
+			return 0, 0, false, err
+		}
+
+		if middleScore >= target {
+			crf, crfScore, ok = middle, middleScore, true
+			low = middle + 1
+			continue
+		}
+
+		if middle == minCRF {
+			if !ok {
+				crfScore = middleScore
+			}
+			break
+		}
+		high = middle - 1
+	}
+
+	if !ok {
+		return minCRF, crfScore, false, nil
+	}
+	return crf, crfScore, true, nil
+}
@@ -0,0 +1,117 @@
+package crfsearch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Samples(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		total    time.Duration
+		count    uint
+		duration time.Duration
+		samples  []Sample
+	}{
+		"unknown duration": {
+			count:    3,
+			duration: 10 * time.Second,
+			samples:  []Sample{{}},
+		},
+		"short video": {
+			total:    20 * time.Second,
+			count:    3,
+			duration: 10 * time.Second,
+			samples:  []Sample{{Duration: 20 * time.Second}},
+		},
+		"long video": {
+			total:    time.Minute,
+			count:    3,
+			duration: 10 * time.Second,
+			samples: []Sample{
+				{Start: 5 * time.Second, Duration: 10 * time.Second},
+				{Start: 25 * time.Second, Duration: 10 * time.Second},
+				{Start: 45 * time.Second, Duration: 10 * time.Second},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			samples := Samples(testCase.total, testCase.count, testCase.duration)
+
+			assert.Equal(t, testCase.samples, samples)
+		})
+	}
+}
+
+func Test_Search(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("test error")
+
+	// linearScore returns a score decreasing by 1 for each CRF increase.
+	linearScore := func(_ context.Context, crf uint) (float64, error) {
+		return 100 - float64(crf), nil
+	}
+
+	testCases := map[string]struct {
+		target     float64
+		score      Score
+		crf        uint
+		crfScore   float64
+		ok         bool
+		errWrapped error
+	}{
+		"target in range": {
+			target:   70.5,
+			score:    linearScore,
+			crf:      29,
+			crfScore: 71,
+			ok:       true,
+		},
+		"target met by max CRF": {
+			target:   10,
+			score:    linearScore,
+			crf:      51,
+			crfScore: 49,
+			ok:       true,
+		},
+		"target not met by min CRF": {
+			target:   99.5,
+			score:    linearScore,
+			crf:      1,
+			crfScore: 99,
+		},
+		"score error": {
+			target: 90,
+			score: func(context.Context, uint) (float64, error) {
+				return 0, errTest
+			},
+			errWrapped: errTest,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			crf, crfScore, ok, err := Search(context.Background(), 1, 51,
+				testCase.target, testCase.score)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			assert.Equal(t, testCase.crf, crf)
+			assert.Equal(t, testCase.crfScore, crfScore)
+			assert.Equal(t, testCase.ok, ok)
+		})
+	}
+}
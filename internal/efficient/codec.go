@@ -0,0 +1,32 @@
+package efficient
+
+import "strings"
+
+// codecName returns the name of the codec produced by the ffmpeg
+// encoder given, as reported by ffprobe. For example it returns
+// `av1` for the `libsvtav1` encoder.
+func codecName(encoder string) (codec string) {
+	encoder = strings.ToLower(encoder)
+	switch encoder {
+	case "libsvtav1", "libaom-av1", "librav1e", "av1_nvenc", "av1_qsv":
+		return "av1"
+	case "libx264", "h264_nvenc", "h264_qsv", "h264_videotoolbox":
+		return "h264"
+	case "libx265", "hevc_nvenc", "hevc_qsv", "hevc_videotoolbox":
+		return "hevc"
+	case "libvpx":
+		return "vp8"
+	case "libvpx-vp9":
+		return "vp9"
+	case "libopus":
+		return "opus"
+	case "libvorbis":
+		return "vorbis"
+	case "libmp3lame":
+		return "mp3"
+	case "libfdk_aac":
+		return "aac"
+	default:
+		return encoder
+	}
+}
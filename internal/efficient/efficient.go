@@ -0,0 +1,76 @@
+// Package efficient determines if a media file is already
+// efficiently encoded, such that converting it again would
+// only waste time and lose quality.
+package efficient
+
+import (
+	"github.com/qdm12/tinier/internal/ffprobe"
+)
+
+// Video returns true if the first video stream of the media
+// information given is encoded with the codec of the ffmpeg
+// encoder given and its bits per pixel per frame is at most maxBitsPerPixel.
+// It returns false if maxBitsPerPixel is 0 or if the bit rate,
+// resolution or frame rate is unknown.
+func Video(info ffprobe.Info, encoder string, maxBitsPerPixel float64) bool {
+	if maxBitsPerPixel == 0 {
+		return false
+	}
+
+	streams := info.VideoStreams()
+	if len(streams) == 0 {
+		return false
+	}
+	stream := streams[0]
+
+	if stream.Codec != codecName(encoder) {
+		return false
+	}
+
+	pixelsPerSecond := float64(stream.Width) * float64(stream.Height) * stream.FrameRate
+	bitRate := streamBitRate(info, stream)
+	if pixelsPerSecond == 0 || bitRate == 0 {
+		return false
+	}
+
+	bitsPerPixel := float64(bitRate) / pixelsPerSecond
+	return bitsPerPixel <= maxBitsPerPixel
+}
+
+// Audio returns true if the first audio stream of the media
+// information given is encoded with the codec of the ffmpeg
+// encoder given and its bit rate is at most maxKbps kilobits per second.
+// It returns false if maxKbps is 0 or if the bit rate is unknown.
+func Audio(info ffprobe.Info, encoder string, maxKbps uint) bool {
+	if maxKbps == 0 {
+		return false
+	}
+
+	streams := info.AudioStreams()
+	if len(streams) == 0 {
+		return false
+	}
+	stream := streams[0]
+
+	if stream.Codec != codecName(encoder) {
+		return false
+	}
+
+	bitRate := streamBitRate(info, stream)
+	if bitRate == 0 {
+		return false
+	}
+
+	const bitsPerKilobit = 1000
+	return bitRate <= uint64(maxKbps)*bitsPerKilobit
+}
+
+// streamBitRate returns the bit rate of the stream, falling back on
+// the overall bit rate of the file for containers such as Matroska
+// which do not report bit rates per stream.
+func streamBitRate(info ffprobe.Info, stream ffprobe.Stream) (bitRate uint64) {
+	if stream.BitRate > 0 {
+		return stream.BitRate
+	}
+	return info.Format.BitRate
+}
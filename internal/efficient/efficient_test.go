@@ -0,0 +1,158 @@
+package efficient
+
+import (
+	"testing"
+
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Video(t *testing.T) {
+	t.Parallel()
+
+	// 1280x720 at 25fps is 23040000 pixels per second
+	av1Stream := ffprobe.Stream{
+		Type:      ffprobe.StreamTypeVideo,
+		Codec:     "av1",
+		Width:     1280,
+		Height:    720,
+		FrameRate: 25,
+		BitRate:   1152000, // 0.05 bits per pixel
+	}
+
+	testCases := map[string]struct {
+		info            ffprobe.Info
+		encoder         string
+		maxBitsPerPixel float64
+		efficient       bool
+	}{
+		"disabled": {
+			info:    ffprobe.Info{Streams: []ffprobe.Stream{av1Stream}},
+			encoder: "libsvtav1",
+		},
+		"no video stream": {
+			encoder:         "libsvtav1",
+			maxBitsPerPixel: 0.1,
+		},
+		"different codec": {
+			info:            ffprobe.Info{Streams: []ffprobe.Stream{av1Stream}},
+			encoder:         "libx265",
+			maxBitsPerPixel: 0.1,
+		},
+		"below threshold": {
+			info:            ffprobe.Info{Streams: []ffprobe.Stream{av1Stream}},
+			encoder:         "libsvtav1",
+			maxBitsPerPixel: 0.1,
+			efficient:       true,
+		},
+		"above threshold": {
+			info:            ffprobe.Info{Streams: []ffprobe.Stream{av1Stream}},
+			encoder:         "libsvtav1",
+			maxBitsPerPixel: 0.01,
+		},
+		"format bit rate fallback": {
+			info: ffprobe.Info{
+				Format: ffprobe.Format{BitRate: 1152000},
+				Streams: []ffprobe.Stream{{
+					Type:      ffprobe.StreamTypeVideo,
+					Codec:     "av1",
+					Width:     1280,
+					Height:    720,
+					FrameRate: 25,
+				}},
+			},
+			encoder:         "libsvtav1",
+			maxBitsPerPixel: 0.1,
+			efficient:       true,
+		},
+		"unknown frame rate": {
+			info: ffprobe.Info{Streams: []ffprobe.Stream{{
+				Type:    ffprobe.StreamTypeVideo,
+				Codec:   "av1",
+				Width:   1280,
+				Height:  720,
+				BitRate: 1152000,
+			}}},
+			encoder:         "libsvtav1",
+			maxBitsPerPixel: 0.1,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			efficient := Video(testCase.info, testCase.encoder, testCase.maxBitsPerPixel)
+
+			assert.Equal(t, testCase.efficient, efficient)
+		})
+	}
+}
+
+func Test_Audio(t *testing.T) {
+	t.Parallel()
+
+	opusStream := ffprobe.Stream{
+		Type:    ffprobe.StreamTypeAudio,
+		Codec:   "opus",
+		BitRate: 48000,
+	}
+
+	testCases := map[string]struct {
+		info      ffprobe.Info
+		encoder   string
+		maxKbps   uint
+		efficient bool
+	}{
+		"disabled": {
+			info:    ffprobe.Info{Streams: []ffprobe.Stream{opusStream}},
+			encoder: "libopus",
+		},
+		"no audio stream": {
+			encoder: "libopus",
+			maxKbps: 64,
+		},
+		"different codec": {
+			info:    ffprobe.Info{Streams: []ffprobe.Stream{opusStream}},
+			encoder: "aac",
+			maxKbps: 64,
+		},
+		"below threshold": {
+			info:      ffprobe.Info{Streams: []ffprobe.Stream{opusStream}},
+			encoder:   "libopus",
+			maxKbps:   64,
+			efficient: true,
+		},
+		"at threshold": {
+			info:      ffprobe.Info{Streams: []ffprobe.Stream{opusStream}},
+			encoder:   "libopus",
+			maxKbps:   48,
+			efficient: true,
+		},
+		"above threshold": {
+			info:    ffprobe.Info{Streams: []ffprobe.Stream{opusStream}},
+			encoder: "libopus",
+			maxKbps: 32,
+		},
+		"unknown bit rate": {
+			info: ffprobe.Info{Streams: []ffprobe.Stream{{
+				Type:  ffprobe.StreamTypeAudio,
+				Codec: "opus",
+			}}},
+			encoder: "libopus",
+			maxKbps: 64,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			efficient := Audio(testCase.info, testCase.encoder, testCase.maxKbps)
+
+			assert.Equal(t, testCase.efficient, efficient)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Package events creates structured events of a run and sends
+// them to a sink, such as a writer of JSON lines for tools to consume.
+package events
+
+import (
+	"time"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/qdm12/tinier/internal/progress"
+	"github.com/qdm12/tinier/internal/report"
+	"github.com/qdm12/tinier/internal/stats"
+)
+
+type Type string
+
+const (
+	TypeRunStarted   Type = "run_started"
+	TypeFileStarted  Type = "file_started"
+	TypeFileProgress Type = "file_progress"
+	TypeFileFinished Type = "file_finished"
+	TypeFileSkipped  Type = "file_skipped"
+	TypeFileFailed   Type = "file_failed"
+	TypeRunFinished  Type = "run_finished"
+)
+
+// Event is a single event of a run. Only the fields relevant
+// to its type are set.
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// Settings are the effective settings of the run,
+	// set for the run_started event.
+	Settings  *config.Settings `json:"settings,omitempty"`
+	InputPath string           `json:"input_path,omitempty"`
+	MediaType models.MediaType `json:"media_type,omitempty"`
+	Progress  *Progress        `json:"progress,omitempty"`
+	// File is the result of processing the file, set for the
+	// file_finished, file_skipped and file_failed events.
+	File *report.File `json:"file,omitempty"`
+	// Stats are the statistics of the run, set for the
+	// run_finished event.
+	Stats *Stats `json:"stats,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Progress is the progress of a video conversion.
+type Progress struct {
+	// Percent is the percentage converted, and is omitted
+	// if the input duration is unknown.
+	Percent        *float64 `json:"percent,omitempty"`
+	Speed          float64  `json:"speed,omitempty"`
+	OutputSize     int64    `json:"output_size"`
+	EncodedSeconds float64  `json:"encoded_seconds"`
+	// ETASeconds is the estimated time remaining in seconds, and is
+	// omitted if the input duration or the speed is unknown.
+	ETASeconds *float64 `json:"eta_seconds,omitempty"`
+}
+
+// Stats are the statistics of a run.
+type Stats struct {
+	Failures   int     `json:"failures"`
+	InputSize  int64   `json:"input_size"`
+	OutputSize int64   `json:"output_size"`
+	Ratio      float64 `json:"ratio,omitempty"`
+	Seconds    float64 `json:"duration_seconds"`
+}
+
+// Sink receives events, and must be safe for concurrent use.
+type Sink interface {
+	Emit(event Event)
+}
+
+// Emitter creates events and sends them to its sink.
+type Emitter struct {
+	sink Sink
+}
+
+// New creates an emitter sending events to the sink given.
+// If the sink is nil, the emitter is disabled and its methods
+// are no-ops.
+func New(sink Sink) *Emitter {
+	return &Emitter{
+		sink: sink,
+	}
+}
+
+// Enabled returns true if the emitter has a sink.
+func (e *Emitter) Enabled() bool {
+	return e.sink != nil
+}
+
+// Emit sets the time of the event given to now
+// and sends it to the sink.
+func (e *Emitter) Emit(event Event) {
+	if !e.Enabled() {
+		return
+	}
+	event.Time = time.Now()
+	e.sink.Emit(event)
+}
+
+// RunStarted emits a run_started event with the effective settings given.
+func (e *Emitter) RunStarted(settings config.Settings) {
+	e.Emit(Event{
+		Type:     TypeRunStarted,
+		Settings: &settings,
+	})
+}
+
+// FileStarted emits a file_started event for the input path given.
+func (e *Emitter) FileStarted(inputPath string, mediaType models.MediaType) {
+	e.Emit(Event{
+		Type:      TypeFileStarted,
+		InputPath: inputPath,
+		MediaType: mediaType,
+	})
+}
+
+// FileProgress emits a file_progress event for the input path given,
+// where total is the duration of the input media, and can be zero
+// if unknown.
+func (e *Emitter) FileProgress(inputPath string, mediaType models.MediaType,
+	ffmpegProgress ffmpeg.Progress, total time.Duration) {
+	eventProgress := &Progress{
+		Speed:          ffmpegProgress.Speed,
+		OutputSize:     ffmpegProgress.TotalSize,
+		EncodedSeconds: ffmpegProgress.OutTime.Seconds(),
+	}
+	percent, ok := progress.Percent(ffmpegProgress, total)
+	if ok {
+		eventProgress.Percent = &percent
+	}
+	eta, ok := progress.ETA(ffmpegProgress, total)
+	if ok {
+		etaSeconds := eta.Seconds()
+		eventProgress.ETASeconds = &etaSeconds
+	}
+
+	e.Emit(Event{
+		Type:      TypeFileProgress,
+		InputPath: inputPath,
+		MediaType: mediaType,
+		Progress:  eventProgress,
+	})
+}
+
+// FileDone emits a file_finished, file_skipped or file_failed
+// event depending on the status of the file given.
+func (e *Emitter) FileDone(file report.File) {
+	event := Event{
+		Type:      TypeFileFinished,
+		InputPath: file.InputPath,
+		MediaType: file.MediaType,
+		File:      &file,
+		Error:     file.Error,
+	}
+	switch file.Status {
+	case report.StatusSkippedExisting, report.StatusSkippedDone:
+		event.Type = TypeFileSkipped
+	case report.StatusFailed:
+		event.Type = TypeFileFailed
+	}
+	e.Emit(event)
+}
+
+// RunFinished emits a run_finished event with the statistics given,
+// and the error given if it is not nil.
+func (e *Emitter) RunFinished(stats *stats.Stats, err error) {
+	failures, inputSize, outputSize := stats.Totals()
+	eventStats := &Stats{
+		Failures:   failures,
+		InputSize:  inputSize,
+		OutputSize: outputSize,
+		Seconds:    time.Since(stats.Start).Seconds(),
+	}
+	if inputSize > 0 {
+		eventStats.Ratio = float64(outputSize) / float64(inputSize)
+	}
+
+	event := Event{
+		Type:  TypeRunFinished,
+		Stats: eventStats,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	e.Emit(event)
+}
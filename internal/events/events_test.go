@@ -0,0 +1,168 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/qdm12/tinier/internal/report"
+	"github.com/qdm12/tinier/internal/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeEvents(t *testing.T, data []byte) (events []Event) {
+	t.Helper()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event Event
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		require.NoError(t, err)
+		assert.False(t, event.Time.IsZero())
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func Test_Emitter_disabled(t *testing.T) {
+	t.Parallel()
+
+	emitter := New(nil)
+
+	assert.False(t, emitter.Enabled())
+	emitter.FileStarted("input/a.png", models.MediaTypeImage)
+}
+
+func Test_Emitter_FileDone(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		file      report.File
+		eventType Type
+		err       string
+	}{
+		"converted": {
+			file:      report.File{Status: report.StatusConverted},
+			eventType: TypeFileFinished,
+		},
+		"already tiny": {
+			file:      report.File{Status: report.StatusAlreadyTiny},
+			eventType: TypeFileFinished,
+		},
+		"skipped existing": {
+			file:      report.File{Status: report.StatusSkippedExisting},
+			eventType: TypeFileSkipped,
+		},
+		"skipped done": {
+			file:      report.File{Status: report.StatusSkippedDone},
+			eventType: TypeFileSkipped,
+		},
+		"failed": {
+			file:      report.File{Status: report.StatusFailed, Error: "oops"},
+			eventType: TypeFileFailed,
+			err:       "oops",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			buffer := bytes.NewBuffer(nil)
+			emitter := New(NewJSONWriter(buffer))
+
+			file := testCase.file
+			file.InputPath = "input/a.png"
+			file.MediaType = models.MediaTypeImage
+			emitter.FileDone(file)
+
+			expected := []Event{{
+				Type:      testCase.eventType,
+				InputPath: "input/a.png",
+				MediaType: models.MediaTypeImage,
+				File:      &file,
+				Error:     testCase.err,
+			}}
+			assert.Equal(t, expected, decodeEvents(t, buffer.Bytes()))
+		})
+	}
+}
+
+func Test_Emitter_FileProgress(t *testing.T) {
+	t.Parallel()
+
+	buffer := bytes.NewBuffer(nil)
+	emitter := New(NewJSONWriter(buffer))
+
+	progress := ffmpeg.Progress{
+		OutTime:   30 * time.Second,
+		Speed:     2,
+		TotalSize: 1000,
+	}
+	emitter.FileProgress("input/a.mp4", models.MediaTypeVideo, progress, time.Minute)
+	emitter.FileProgress("input/a.mp4", models.MediaTypeVideo, progress, 0)
+
+	percent := 50.0
+	etaSeconds := 15.0
+	expected := []Event{{
+		Type:      TypeFileProgress,
+		InputPath: "input/a.mp4",
+		MediaType: models.MediaTypeVideo,
+		Progress: &Progress{
+			Percent:        &percent,
+			Speed:          2,
+			OutputSize:     1000,
+			EncodedSeconds: 30,
+			ETASeconds:     &etaSeconds,
+		},
+	}, {
+		Type:      TypeFileProgress,
+		InputPath: "input/a.mp4",
+		MediaType: models.MediaTypeVideo,
+		Progress: &Progress{
+			Speed:          2,
+			OutputSize:     1000,
+			EncodedSeconds: 30,
+		},
+	}}
+	assert.Equal(t, expected, decodeEvents(t, buffer.Bytes()))
+}
+
+func Test_Emitter_RunFinished(t *testing.T) {
+	t.Parallel()
+
+	buffer := bytes.NewBuffer(nil)
+	emitter := New(NewJSONWriter(buffer))
+
+	stats := stats.New()
+	stats.AddFailure()
+	stats.AddSizes(100, 25)
+	emitter.RunFinished(stats, errors.New("context canceled"))
+
+	events := decodeEvents(t, buffer.Bytes())
+	require.Len(t, events, 1)
+	event := events[0]
+	require.NotNil(t, event.Stats)
+	assert.GreaterOrEqual(t, event.Stats.Seconds, 0.0)
+	event.Stats.Seconds = 0
+
+	expected := Event{
+		Type: TypeRunFinished,
+		Stats: &Stats{
+			Failures:   1,
+			InputSize:  100,
+			OutputSize: 25,
+			Ratio:      0.25,
+		},
+		Error: "context canceled",
+	}
+	assert.Equal(t, expected, event)
+}
@@ -0,0 +1,28 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSONWriter is a sink writing events as JSON to its writer,
+// one event per line. It is safe for concurrent use.
+type JSONWriter struct {
+	encoder *json.Encoder
+	mutex   sync.Mutex
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Emit writes the event given as a single JSON line. Write errors
+// are ignored, since events are informational only.
+func (j *JSONWriter) Emit(event Event) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	_ = j.encoder.Encode(event)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+
+	"github.com/qdm12/tinier/internal/cmd"
 )
 
 func (f *FFMPEG) TinyAudio(ctx context.Context, inputPath, outputPath,
@@ -35,7 +37,7 @@ func (f *FFMPEG) TinyAudio(ctx context.Context, inputPath, outputPath,
 	args = append(args, outputPath)
 
 	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
-	patchCmd(execCmd)
+	cmd.SetProcessGroup(execCmd)
 
 	f.logger.Debug(execCmd.String())
 
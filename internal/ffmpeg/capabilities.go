@@ -0,0 +1,98 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Capabilities is the set of encoders, muxers and filters
+// available in an ffmpeg build.
+type Capabilities struct {
+	encoders map[string]struct{}
+	muxers   map[string]struct{}
+	filters  map[string]struct{}
+}
+
+// HasEncoder returns true if the encoder given, for example
+// `libwebp`, is available.
+func (c Capabilities) HasEncoder(name string) bool {
+	_, ok := c.encoders[name]
+	return ok
+}
+
+// HasMuxer returns true if the muxer given, for example
+// `webm`, is available.
+func (c Capabilities) HasMuxer(name string) bool {
+	_, ok := c.muxers[name]
+	return ok
+}
+
+// HasFilter returns true if the filter given, for example
+// `libvmaf`, is available.
+func (c Capabilities) HasFilter(name string) bool {
+	_, ok := c.filters[name]
+	return ok
+}
+
+// DetectCapabilities lists the encoders, muxers and filters of the
+// ffmpeg build, and stores them to be returned by Capabilities.
+// It must be called before any call to Capabilities.
+func (f *FFMPEG) DetectCapabilities(ctx context.Context) (err error) {
+	f.capabilities.encoders, err = f.list(ctx, "-encoders")
+	if err != nil {
+		return fmt.Errorf("listing encoders: %w", err)
+	}
+
+	f.capabilities.muxers, err = f.list(ctx, "-muxers")
+	if err != nil {
+		return fmt.Errorf("listing muxers: %w", err)
+	}
+
+	f.capabilities.filters, err = f.list(ctx, "-filters")
+	if err != nil {
+		return fmt.Errorf("listing filters: %w", err)
+	}
+
+	return nil
+}
+
+// Capabilities returns the capabilities found by DetectCapabilities.
+func (f *FFMPEG) Capabilities() Capabilities {
+	return f.capabilities
+}
+
+func (f *FFMPEG) list(ctx context.Context, option string) (
+	names map[string]struct{}, err error) {
+	execCmd := exec.CommandContext(ctx, f.binPath, "-hide_banner", option) //nolint:gosec
+	f.logger.Debug(execCmd.String())
+	output, err := f.cmd.Run(execCmd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, output)
+	}
+	return parseListing(output), nil
+}
+
+// parseListing returns the names listed in the output of an ffmpeg
+// listing option such as `-encoders`, `-muxers` or `-filters`, where
+// each indented line starts with a field of capability flags followed
+// by a comma separated list of names, for example:
+// ` V....D libwebp  libwebp WebP image (codec webp)`.
+func parseListing(output string) (names map[string]struct{}) {
+	names = make(map[string]struct{})
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, " ") { // title line such as `File formats:`
+			continue
+		}
+		fields := strings.Fields(line)
+		const minFields = 2
+		if len(fields) < minFields || fields[1] == "=" { // legend line
+			continue
+		}
+		for _, name := range strings.Split(fields[1], ",") {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}
@@ -0,0 +1,55 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseListing(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		output string
+		names  map[string]struct{}
+	}{
+		"empty": {
+			names: map[string]struct{}{},
+		},
+		"encoders": {
+			output: `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libwebp              libwebp WebP image (codec webp)
+ A....D libopus              libopus Opus (codec opus)
+`,
+			names: map[string]struct{}{"libwebp": {}, "libopus": {}},
+		},
+		"muxers": {
+			output: `File formats:
+ D. = Demuxing supported
+ .E = Muxing supported
+ --
+  E matroska        Matroska
+  E mp4             MP4 (MPEG-4 Part 14)
+`,
+			names: map[string]struct{}{"matroska": {}, "mp4": {}},
+		},
+		"comma separated names": {
+			output: " DE mov,mp4,m4a  QuickTime / MOV\n",
+			names:  map[string]struct{}{"mov": {}, "mp4": {}, "m4a": {}},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			names := parseListing(testCase.output)
+
+			assert.Equal(t, testCase.names, names)
+		})
+	}
+}
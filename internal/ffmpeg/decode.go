@@ -0,0 +1,44 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/qdm12/tinier/internal/cmd"
+)
+
+var ErrDecoding = errors.New("failed FFMPEG decoding")
+
+// Decode decodes all the streams of the file at the path given
+// without writing any output, and returns an error if ffmpeg
+// fails or reports any decoding error.
+func (f *FFMPEG) Decode(ctx context.Context, path string) (err error) {
+	args := []string{
+		"-hide_banner",
+		"-v", "error",
+		"-i", path,
+		"-f", "null",
+		"-",
+	}
+
+	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
+	cmd.SetProcessGroup(execCmd)
+
+	f.logger.Debug(execCmd.String())
+
+	output, err := f.cmd.Run(execCmd)
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		return fmt.Errorf("%w: %s", ErrDecoding, output)
+	case output != "":
+		// ffmpeg exits successfully despite corrupt data,
+		// but logs errors at the error log level.
+		return fmt.Errorf("%w: %s", ErrDecoding, output)
+	default:
+		return nil
+	}
+}
@@ -1,9 +0,0 @@
-//go:build windows
-
-package ffmpeg
-
-import (
-	"os/exec"
-)
-
-func patchCmd(cmd *exec.Cmd) {}
@@ -9,6 +9,8 @@ type FFMPEG struct {
 	binPath    string
 	minVersion semver.Semver
 	logger     Logger
+	// capabilities is set by DetectCapabilities.
+	capabilities Capabilities
 }
 
 func New(cmd Runner, binPath string,
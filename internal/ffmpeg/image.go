@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+
+	"github.com/qdm12/tinier/internal/cmd"
 )
 
 var (
@@ -12,35 +14,108 @@ var (
 	ErrConversion       = errors.New("failed FFMPEG conversion")
 )
 
+// ImageOptions contains the encoding options of an image,
+// where only the options of the codec set are used.
+type ImageOptions struct {
+	// Codec is the ffmpeg image encoder, which is one of `mjpeg`,
+	// `libaom-av1`, `libsvtav1`, `libwebp`, `libjxl` or `png`.
+	Codec string
+	// QScale is the constant quantizer for `mjpeg`.
+	QScale uint
+	// CRF is the constant quality for `libaom-av1` and `libsvtav1`.
+	CRF uint
+	// Quality is the quality from 0 to 100 for `libwebp`.
+	Quality uint
+	// Lossless is for lossless `libwebp` encoding, in which
+	// case Quality is ignored.
+	Lossless bool
+	// Distance is the Butteraugli distance for `libjxl`,
+	// where 0 is lossless and 1 is visually lossless.
+	Distance float64
+	// Effort is the encoding effort from 1 to 9 for `libjxl`.
+	Effort uint
+	// CompressionLevel is the zlib compression level from 1 to 9
+	// for `png`.
+	CompressionLevel uint
+	// Palette is true to store the image with a palette for `png`,
+	// which is lossless only if the image has at most 255 colors
+	// and no partially transparent pixel.
+	Palette bool
+	// Alpha is true if the input image has an alpha channel, in which
+	// case it is encoded as a second video stream for `libaom-av1`
+	// and `libsvtav1`, as expected by the AVIF muxer. Other codecs
+	// supporting an alpha channel keep it in their single stream.
+	Alpha bool
+}
+
 func (f *FFMPEG) TinyImage(ctx context.Context, inputPath, outputPath,
-	codec, scale string, crf, qScale uint) (err error) {
+	scale string, options ImageOptions) (err error) {
 	args := []string{
 		"-y",
 		"-hide_banner",
 		"-loglevel", "warning",
 		"-i", inputPath,
-		"-vf", "scale=" + scale,
-		"-map_metadata", "0",
-		"-movflags", "use_metadata_tags",
-		"-c:v", codec,
 	}
 
-	switch codec {
+	isAV1 := options.Codec == "libaom-av1" || options.Codec == "libsvtav1"
+	if options.Alpha && isAV1 {
+		args = append(args,
+			"-filter_complex", "[0:v]scale="+scale+
+				",split[color][alpha];[alpha]alphaextract[alphaplane]",
+			"-map", "[color]",
+			"-map", "[alphaplane]")
+	} else if options.Palette && options.Codec == "png" {
+		args = append(args, "-vf", "scale="+scale+
+			",split[image][palettesource];"+
+			"[palettesource]palettegen=max_colors=256:reserve_transparent=1[palette];"+
+			"[image][palette]paletteuse=dither=none")
+	} else {
+		args = append(args, "-vf", "scale="+scale)
+	}
+
+	if options.Codec == "png" {
+		// strip ancillary text and Exif chunks
+		args = append(args, "-map_metadata", "-1")
+	} else {
+		args = append(args,
+			"-map_metadata", "0",
+			"-movflags", "use_metadata_tags")
+	}
+	args = append(args, "-c:v", options.Codec)
+
+	switch options.Codec {
 	case "libaom-av1":
 		args = append(args,
 			"-still-picture", "1",
-			"-crf", fmt.Sprint(crf))
+			"-crf", fmt.Sprint(options.CRF))
+	case "libsvtav1":
+		args = append(args,
+			"-crf", fmt.Sprint(options.CRF))
 	case "mjpeg":
 		args = append(args,
-			"-qscale:v", fmt.Sprint(qScale))
+			"-qscale:v", fmt.Sprint(options.QScale))
+	case "libwebp":
+		if options.Lossless {
+			args = append(args, "-lossless", "1")
+		} else {
+			args = append(args, "-quality", fmt.Sprint(options.Quality))
+		}
+	case "libjxl":
+		args = append(args,
+			"-distance", fmt.Sprint(options.Distance),
+			"-effort", fmt.Sprint(options.Effort))
+	case "png":
+		args = append(args,
+			"-compression_level", fmt.Sprint(options.CompressionLevel),
+			"-pred", "mixed")
 	default:
-		return fmt.Errorf("%w: %s", ErrCodecUnsupported, codec)
+		return fmt.Errorf("%w: %s", ErrCodecUnsupported, options.Codec)
 	}
 
 	args = append(args, outputPath, "-noautorotate")
 
 	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
-	patchCmd(execCmd)
+	cmd.SetProcessGroup(execCmd)
 
 	f.logger.Debug(execCmd.String())
 
@@ -8,6 +8,7 @@ import (
 
 type Runner interface {
 	Run(cmd cmd.ExecCmd) (output string, err error)
+	Stream(cmd cmd.ExecCmd, onStdoutLine, onStderrLine func(line string)) (output string, err error)
 }
 
 type HTTPClient interface {
@@ -0,0 +1,57 @@
+package ffmpeg
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress is the progress of an ffmpeg conversion.
+type Progress struct {
+	// OutTime is the duration of media encoded so far.
+	OutTime time.Duration
+	// Speed is the encoding speed relative to the media
+	// playback speed, for example 1.8 for 1.8x.
+	Speed float64
+	// TotalSize is the size in bytes of the output so far.
+	TotalSize int64
+	// Done is true once the conversion is complete.
+	Done bool
+}
+
+// progressParser parses the key=value lines written by ffmpeg
+// with the `-progress` option, and calls its onProgress function
+// at the end of each block of lines.
+type progressParser struct {
+	progress   Progress
+	onProgress func(progress Progress)
+}
+
+func (p *progressParser) parseLine(line string) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return
+	}
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "out_time_us", "out_time_ms": // out_time_ms is in microseconds as well
+		microseconds, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && microseconds >= 0 {
+			p.progress.OutTime = time.Duration(microseconds) * time.Microsecond
+		}
+	case "speed":
+		speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+		if err == nil {
+			p.progress.Speed = speed
+		}
+	case "total_size":
+		totalSize, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			p.progress.TotalSize = totalSize
+		}
+	case "progress":
+		p.progress.Done = value == "end"
+		p.onProgress(p.progress)
+	}
+}
@@ -0,0 +1,47 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_progressParser_parseLine(t *testing.T) {
+	t.Parallel()
+
+	lines := []string{
+		"frame=120",
+		"fps=59.94",
+		"total_size=262192",
+		"out_time_us=4004000",
+		"out_time_ms=4004000",
+		"out_time=00:00:04.004000",
+		"speed=1.99x",
+		"progress=continue",
+		"malformed line",
+		"total_size=N/A",
+		"out_time_us=N/A",
+		"speed=N/A",
+		"total_size=524288",
+		"out_time_us=8008000",
+		"speed=2.01x",
+		"progress=end",
+	}
+
+	var progresses []Progress
+	parser := &progressParser{
+		onProgress: func(progress Progress) {
+			progresses = append(progresses, progress)
+		},
+	}
+	for _, line := range lines {
+		parser.parseLine(line)
+	}
+
+	expected := []Progress{
+		{OutTime: 4004 * time.Millisecond, Speed: 1.99, TotalSize: 262192},
+		{OutTime: 8008 * time.Millisecond, Speed: 2.01, TotalSize: 524288, Done: true},
+	}
+	assert.Equal(t, expected, progresses)
+}
@@ -0,0 +1,107 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/qdm12/tinier/internal/cmd"
+)
+
+var (
+	ErrQualityMetricUnsupported = errors.New("quality metric unsupported")
+	ErrQualityComputation       = errors.New("failed FFMPEG quality computation")
+	ErrQualityScoreNotFound     = errors.New("quality score not found")
+)
+
+// Quality computes the quality score of the distorted file compared
+// to the reference file, using the metric given which can be `ssim`,
+// `psnr` or `vmaf`. The reference file is scaled to the resolution
+// of the distorted file before comparing them.
+func (f *FFMPEG) Quality(ctx context.Context, referencePath, distortedPath,
+	metric string) (score float64, err error) {
+	var filter string
+	switch metric {
+	case "ssim", "psnr":
+		filter = metric
+	case "vmaf":
+		filter = "libvmaf"
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrQualityMetricUnsupported, metric)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-nostats",
+		"-i", distortedPath,
+		"-i", referencePath,
+		"-lavfi", "[1:v][0:v]scale2ref=flags=bicubic[ref][dist];" +
+			"[dist]setsar=1[distsar];[ref]setsar=1[refsar];" +
+			"[distsar][refsar]" + filter,
+		"-f", "null",
+		"-",
+	}
+
+	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
+	cmd.SetProcessGroup(execCmd)
+
+	f.logger.Debug(execCmd.String())
+
+	output, err := f.cmd.Run(execCmd)
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	} else if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrQualityComputation, output)
+	}
+
+	return parseQualityScore(output, metric)
+}
+
+var (
+	regexSSIMScore = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+	regexPSNRScore = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+	regexVMAFScore = regexp.MustCompile(`VMAF score: ([0-9.]+)`)
+)
+
+// parseQualityScore parses the score of the metric given from the
+// ffmpeg output given. An infinite PSNR score, for identical files,
+// is returned as the maximum float64 value.
+func parseQualityScore(output, metric string) (score float64, err error) {
+	var regex *regexp.Regexp
+	switch metric {
+	case "ssim":
+		regex = regexSSIMScore
+	case "psnr":
+		regex = regexPSNRScore
+	case "vmaf":
+		regex = regexVMAFScore
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrQualityMetricUnsupported, metric)
+	}
+
+	// Use the last match in case the metric is logged more than once.
+	var match []string
+	for _, line := range strings.Split(output, "\n") {
+		if lineMatch := regex.FindStringSubmatch(line); lineMatch != nil {
+			match = lineMatch
+		}
+	}
+	if match == nil {
+		return 0, fmt.Errorf("%w: in output: %s", ErrQualityScoreNotFound, output)
+	}
+
+	if match[1] == "inf" {
+		return math.MaxFloat64, nil
+	}
+
+	score, err = strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing quality score: %w", err)
+	}
+	return score, nil
+}
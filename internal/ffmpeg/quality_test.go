@@ -0,0 +1,63 @@
+package ffmpeg
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseQualityScore(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		output     string
+		metric     string
+		score      float64
+		errWrapped error
+	}{
+		"ssim": {
+			output: "[Parsed_ssim_4 @ 0x5581] SSIM Y:0.987443 (19.009) " +
+				"U:0.991 (20.6) V:0.990 (20.3) All:0.988799 (19.508)",
+			metric: "ssim",
+			score:  0.988799,
+		},
+		"psnr": {
+			output: "[Parsed_psnr_4 @ 0x5581] PSNR y:41.27 u:44.63 v:44.99 " +
+				"average:42.192417 min:40.11 max:45.98",
+			metric: "psnr",
+			score:  42.192417,
+		},
+		"psnr identical": {
+			output: "[Parsed_psnr_4 @ 0x5581] PSNR y:inf u:inf v:inf average:inf min:inf max:inf",
+			metric: "psnr",
+			score:  math.MaxFloat64,
+		},
+		"vmaf": {
+			output: "[Parsed_libvmaf_4 @ 0x5581] VMAF score: 93.416071",
+			metric: "vmaf",
+			score:  93.416071,
+		},
+		"score not found": {
+			output:     "Output #0, null, to 'pipe:':",
+			metric:     "ssim",
+			errWrapped: ErrQualityScoreNotFound,
+		},
+		"unsupported metric": {
+			metric:     "butteraugli",
+			errWrapped: ErrQualityMetricUnsupported,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			score, err := parseQualityScore(testCase.output, testCase.metric)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			assert.Equal(t, testCase.score, score)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/qdm12/tinier/internal/cmd"
+)
+
+// ExtractSample copies the first video stream of the input file from
+// the start position and for the duration given to the output path,
+// without encoding it again. A zero duration copies until the end.
+func (f *FFMPEG) ExtractSample(ctx context.Context, inputPath, outputPath string,
+	start, duration time.Duration) (err error) {
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-ss", formatSeconds(start),
+		"-i", inputPath,
+	}
+	if duration > 0 {
+		args = append(args, "-t", formatSeconds(duration))
+	}
+	args = append(args,
+		"-map", "0:v:0",
+		"-c", "copy",
+		outputPath,
+	)
+
+	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
+	cmd.SetProcessGroup(execCmd)
+
+	f.logger.Debug(execCmd.String())
+
+	output, err := f.cmd.Run(execCmd)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	} else if err != nil {
+		return fmt.Errorf("%w: %s", ErrConversion, output)
+	}
+	return nil
+}
+
+func formatSeconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/qdm12/tinier/internal/cmd"
 	"github.com/qdm12/tinier/internal/semver"
 )
 
@@ -15,9 +16,9 @@ func (f *FFMPEG) Version(ctx context.Context) (version semver.Semver, err error)
 
 func getVersion(ctx context.Context, path string, runner Runner) (
 	version semver.Semver, err error) {
-	cmd := exec.CommandContext(ctx, path, "-version")
-	patchCmd(cmd)
-	s, err := runner.Run(cmd)
+	execCmd := exec.CommandContext(ctx, path, "-version")
+	cmd.SetProcessGroup(execCmd)
+	s, err := runner.Run(execCmd)
 	if err != nil {
 		return version, err
 	}
@@ -4,31 +4,130 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strings"
+
+	"github.com/qdm12/tinier/internal/cmd"
 )
 
+// TinyVideo converts the input video to the output path.
+// If onProgress is not nil, it is called with the progress of
+// the conversion about twice a second.
 func (f *FFMPEG) TinyVideo(ctx context.Context, inputPath, outputPath,
-	scale, preset, codec string, crf uint) (err error) {
-	args := []string{
+	scale, preset, codec string, crf uint,
+	onProgress func(progress Progress)) (err error) {
+	args := videoArgs(inputPath, scale, preset, codec)
+	args = append(args,
+		"-crf", fmt.Sprint(crf),
+		"-c:a", "copy",
+		"-map_metadata", "0",
+		"-movflags", "use_metadata_tags",
+		outputPath,
+	)
+	return f.runVideo(ctx, args, onProgress)
+}
+
+// TinyVideoBitRate converts the input video to the output path, with
+// the video bit rate given in bits per second. The video is encoded in
+// two passes if the codec supports it, using the pass log file path
+// prefix given for the first pass statistics.
+// If onProgress is not nil, it is called with the progress of the
+// last pass about twice a second.
+func (f *FFMPEG) TinyVideoBitRate(ctx context.Context, inputPath, outputPath,
+	scale, preset, codec string, bitRate uint64, passLogPrefix string,
+	onProgress func(progress Progress)) (err error) {
+	bitRateArgs := []string{"-b:v", fmt.Sprint(bitRate)}
+	outputArgs := []string{
+		"-c:a", "copy",
+		"-map_metadata", "0",
+		"-movflags", "use_metadata_tags",
+		outputPath,
+	}
+
+	if !SupportsTwoPass(codec) {
+		args := videoArgs(inputPath, scale, preset, codec)
+		args = append(args, bitRateArgs...)
+		args = append(args, outputArgs...)
+		return f.runVideo(ctx, args, onProgress)
+	}
+
+	for pass := 1; pass <= 2; pass++ {
+		args := videoArgs(inputPath, scale, preset, codec)
+		args = append(args, bitRateArgs...)
+		args = append(args, passArgs(codec, pass, passLogPrefix)...)
+		if pass == 1 {
+			// The first pass only writes the pass log file.
+			args = append(args, "-an", "-f", "null", "-")
+			err = f.runVideo(ctx, args, nil)
+			if err != nil {
+				return fmt.Errorf("first pass: %w", err)
+			}
+			continue
+		}
+		args = append(args, outputArgs...)
+		err = f.runVideo(ctx, args, onProgress)
+		if err != nil {
+			return fmt.Errorf("second pass: %w", err)
+		}
+	}
+	return nil
+}
+
+// SupportsTwoPass returns true if the ffmpeg video encoder
+// given supports two pass encoding.
+func SupportsTwoPass(codec string) bool {
+	switch strings.ToLower(codec) {
+	case "libx264", "libx265", "libvpx", "libvpx-vp9", "libaom-av1":
+		return true
+	default:
+		return false
+	}
+}
+
+func passArgs(codec string, pass int, passLogPrefix string) (args []string) {
+	if strings.ToLower(codec) == "libx265" {
+		// libx265 ignores the -pass option.
+		return []string{"-x265-params",
+			fmt.Sprintf("pass=%d:stats=%s.log", pass, passLogPrefix)}
+	}
+	return []string{
+		"-pass", fmt.Sprint(pass),
+		"-passlogfile", passLogPrefix,
+	}
+}
+
+func videoArgs(inputPath, scale, preset, codec string) (args []string) {
+	return []string{
 		"-y",
 		"-hide_banner",
 		"-loglevel", "warning",
 		"-i", inputPath,
 		"-vf", "scale='" + scale + "',crop='iw-mod(iw,2)':'ih-mod(ih,2)'",
 		"-vcodec", codec,
-		"-crf", fmt.Sprint(crf),
-		"-c:a", "copy",
 		"-preset", preset,
-		"-map_metadata", "0",
-		"-movflags", "use_metadata_tags",
-		outputPath,
+	}
+}
+
+// runVideo runs ffmpeg with the arguments given, and calls onProgress
+// with the progress of the conversion if it is not nil.
+func (f *FFMPEG) runVideo(ctx context.Context, args []string,
+	onProgress func(progress Progress)) (err error) {
+	if onProgress != nil {
+		// Global options must be before the first input.
+		args = append([]string{"-progress", "pipe:1", "-nostats"}, args...)
 	}
 
 	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
-	patchCmd(execCmd)
+	cmd.SetProcessGroup(execCmd)
 
 	f.logger.Debug(execCmd.String())
 
-	output, err := f.cmd.Run(execCmd)
+	var output string
+	if onProgress == nil {
+		output, err = f.cmd.Run(execCmd)
+	} else {
+		parser := &progressParser{onProgress: onProgress}
+		output, err = f.cmd.Stream(execCmd, parser.parseLine, nil)
+	}
 	if ctx.Err() != nil {
 		return ctx.Err()
 	} else if err != nil {
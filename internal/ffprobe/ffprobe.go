@@ -0,0 +1,15 @@
+package ffprobe
+
+type FFProbe struct {
+	cmd     Runner
+	binPath string
+	logger  Logger
+}
+
+func New(cmd Runner, binPath string, logger Logger) *FFProbe {
+	return &FFProbe{
+		cmd:     cmd,
+		binPath: binPath,
+		logger:  logger,
+	}
+}
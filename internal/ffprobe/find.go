@@ -0,0 +1,40 @@
+package ffprobe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var ErrFFProbeBinNotFound = errors.New("ffprobe binary file not found")
+
+// Find returns the path to the `ffprobe` binary located in the
+// same directory as the `ffmpeg` binary path given, which is the
+// case for most ffmpeg installations and for the static builds
+// downloaded by tinier. If it is not found there, it falls back
+// on any `ffprobe` found in the system path.
+func Find(ffmpegPath string) (ffprobePath string, err error) {
+	binName := "ffprobe"
+	if strings.HasSuffix(ffmpegPath, ".exe") {
+		binName += ".exe"
+	}
+
+	ffprobePath = filepath.Join(filepath.Dir(ffmpegPath), binName)
+	_, err = os.Stat(ffprobePath)
+	if err == nil {
+		return ffprobePath, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("stating ffprobe binary: %w", err)
+	}
+
+	ffprobePath, err = exec.LookPath("ffprobe")
+	if err == nil {
+		return ffprobePath, nil
+	}
+
+	return "", fmt.Errorf("%w: next to %s or in the system path",
+		ErrFFProbeBinNotFound, ffmpegPath)
+}
@@ -0,0 +1,24 @@
+package ffprobe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Find(t *testing.T) {
+	t.Parallel()
+
+	dirPath := t.TempDir()
+	ffprobePath := filepath.Join(dirPath, "ffprobe")
+	err := os.WriteFile(ffprobePath, nil, 0o600)
+	require.NoError(t, err)
+
+	path, err := Find(filepath.Join(dirPath, "ffmpeg"))
+
+	require.NoError(t, err)
+	assert.Equal(t, ffprobePath, path)
+}
@@ -0,0 +1,110 @@
+package ffprobe
+
+import (
+	"strings"
+	"time"
+)
+
+// Info contains the media information of a file.
+type Info struct {
+	Format  Format
+	Streams []Stream
+}
+
+// Format contains the container information of a file.
+type Format struct {
+	// Name is the comma separated list of container
+	// format names, for example "mov,mp4,m4a,3gp,3g2,mj2".
+	Name     string
+	Duration time.Duration
+	// BitRate is the overall bit rate in bits per second.
+	BitRate uint64
+	// Size is the file size in bytes.
+	Size int64
+	Tags map[string]string
+}
+
+type StreamType string
+
+const (
+	StreamTypeVideo    StreamType = "video"
+	StreamTypeAudio    StreamType = "audio"
+	StreamTypeSubtitle StreamType = "subtitle"
+	StreamTypeData     StreamType = "data"
+)
+
+// Stream contains the information of a single stream of a file.
+// Video specific fields are zero for non-video streams, and audio
+// specific fields are zero for non-audio streams.
+type Stream struct {
+	Index int
+	Type  StreamType
+	// Codec is the codec name, for example "h264" or "opus".
+	Codec    string
+	Duration time.Duration
+	// BitRate is the stream bit rate in bits per second, and
+	// is zero if unknown.
+	BitRate uint64
+	Tags    map[string]string
+
+	Width  uint
+	Height uint
+	// FrameRate is the average frame rate in frames per second.
+	FrameRate float64
+	// Rotation is the clockwise rotation in degrees to apply
+	// to the video frames for display, in the range [0, 360).
+	Rotation    int
+	PixelFormat string
+
+	Channels   uint
+	SampleRate uint
+}
+
+// VideoStreams returns the video streams of the file.
+func (i Info) VideoStreams() (streams []Stream) {
+	return i.streams(StreamTypeVideo)
+}
+
+// AudioStreams returns the audio streams of the file.
+func (i Info) AudioStreams() (streams []Stream) {
+	return i.streams(StreamTypeAudio)
+}
+
+func (i Info) streams(streamType StreamType) (streams []Stream) {
+	for _, stream := range i.Streams {
+		if stream.Type == streamType {
+			streams = append(streams, stream)
+		}
+	}
+	return streams
+}
+
+// DisplaySize returns the width and height of the video stream
+// once rotated for display.
+func (s Stream) DisplaySize() (width, height uint) {
+	const quarterTurn, threeQuarterTurn = 90, 270
+	if s.Rotation == quarterTurn || s.Rotation == threeQuarterTurn {
+		return s.Height, s.Width
+	}
+	return s.Width, s.Height
+}
+
+// HasAlpha returns true if the pixel format of the stream has or
+// may have an alpha channel, for example `rgba`, `ya8` or `yuva420p`.
+// Palette pixel formats are considered with an alpha channel, since
+// their palette may have transparent colors, see HasPalette.
+func (s Stream) HasAlpha() bool {
+	alphaPrefixes := [...]string{"rgba", "bgra", "argb", "abgr", "ya8", "ya16", "yuva", "gbrap", "pal8"}
+	for _, prefix := range alphaPrefixes {
+		if strings.HasPrefix(s.PixelFormat, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPalette returns true if the pixel format of the stream is a
+// palette pixel format, such as for PNG images with a palette.
+func (s Stream) HasPalette() bool {
+	return s.PixelFormat == "pal8"
+}
@@ -0,0 +1,38 @@
+package ffprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Stream_HasAlpha(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		pixelFormat string
+		hasAlpha    bool
+	}{
+		"unknown":            {},
+		"rgb24":              {pixelFormat: "rgb24"},
+		"palette":            {pixelFormat: "pal8", hasAlpha: true},
+		"yuv420p":            {pixelFormat: "yuv420p"},
+		"rgba":               {pixelFormat: "rgba", hasAlpha: true},
+		"rgba 16 bits":       {pixelFormat: "rgba64be", hasAlpha: true},
+		"gray with alpha":    {pixelFormat: "ya8", hasAlpha: true},
+		"yuv420p with alpha": {pixelFormat: "yuva420p", hasAlpha: true},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := Stream{PixelFormat: testCase.pixelFormat}
+
+			hasAlpha := stream.HasAlpha()
+
+			assert.Equal(t, testCase.hasAlpha, hasAlpha)
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package ffprobe
+
+import (
+	"github.com/qdm12/tinier/internal/cmd"
+)
+
+type Runner interface {
+	Stream(cmd cmd.ExecCmd, onStdoutLine, onStderrLine func(line string)) (
+		output string, err error)
+}
+
+type Logger interface {
+	Debug(msg string)
+}
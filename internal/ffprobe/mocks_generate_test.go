@@ -0,0 +1,3 @@
+package ffprobe
+
+//go:generate mockgen -destination=mocks_test.go -package=$GOPACKAGE . Runner
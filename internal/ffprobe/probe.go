@@ -0,0 +1,258 @@
+package ffprobe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qdm12/tinier/internal/cmd"
+)
+
+var ErrProbe = errors.New("failed FFProbe probe")
+
+// Probe returns the media information of the file at the given path.
+func (f *FFProbe) Probe(ctx context.Context, filePath string) (info Info, err error) {
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		filePath,
+	}
+
+	execCmd := exec.CommandContext(ctx, f.binPath, args...) //nolint:gosec
+	cmd.SetProcessGroup(execCmd)
+
+	f.logger.Debug(execCmd.String())
+
+	// Only the standard output is parsed as JSON, since ffprobe
+	// can log warnings to its standard error and still succeed.
+	stdout := new(strings.Builder)
+	stderr, err := f.cmd.Stream(execCmd, func(line string) {
+		stdout.WriteString(line + "\n")
+	}, nil)
+	if ctx.Err() != nil {
+		return info, ctx.Err()
+	} else if err != nil {
+		return info, fmt.Errorf("%w: %s", ErrProbe, stderr)
+	}
+
+	info, err = parse(stdout.String())
+	if err != nil {
+		return info, fmt.Errorf("parsing ffprobe output: %w", err)
+	}
+	return info, nil
+}
+
+type jsonOutput struct {
+	Format  jsonFormat   `json:"format"`
+	Streams []jsonStream `json:"streams"`
+}
+
+type jsonFormat struct {
+	FormatName string            `json:"format_name"`
+	Duration   string            `json:"duration"`
+	BitRate    string            `json:"bit_rate"`
+	Size       string            `json:"size"`
+	Tags       map[string]string `json:"tags"`
+}
+
+type jsonStream struct {
+	Index        int               `json:"index"`
+	CodecType    string            `json:"codec_type"`
+	CodecName    string            `json:"codec_name"`
+	Duration     string            `json:"duration"`
+	BitRate      string            `json:"bit_rate"`
+	Tags         map[string]string `json:"tags"`
+	Width        uint              `json:"width"`
+	Height       uint              `json:"height"`
+	AvgFrameRate string            `json:"avg_frame_rate"`
+	RFrameRate   string            `json:"r_frame_rate"`
+	PixFmt       string            `json:"pix_fmt"`
+	Channels     uint              `json:"channels"`
+	SampleRate   string            `json:"sample_rate"`
+	SideDataList []struct {
+		SideDataType string  `json:"side_data_type"`
+		Rotation     float64 `json:"rotation"`
+	} `json:"side_data_list"`
+}
+
+func parse(output string) (info Info, err error) {
+	var data jsonOutput
+	err = json.Unmarshal([]byte(output), &data)
+	if err != nil {
+		return info, fmt.Errorf("decoding JSON: %w", err)
+	}
+
+	info.Format, err = parseFormat(data.Format)
+	if err != nil {
+		return info, fmt.Errorf("parsing format: %w", err)
+	}
+
+	info.Streams = make([]Stream, len(data.Streams))
+	for i, jsonStream := range data.Streams {
+		info.Streams[i], err = parseStream(jsonStream)
+		if err != nil {
+			return info, fmt.Errorf("parsing stream %d: %w", jsonStream.Index, err)
+		}
+	}
+
+	return info, nil
+}
+
+func parseFormat(data jsonFormat) (format Format, err error) {
+	format.Name = data.FormatName
+	format.Tags = data.Tags
+
+	format.Duration, err = parseDuration(data.Duration)
+	if err != nil {
+		return format, fmt.Errorf("parsing duration: %w", err)
+	}
+
+	format.BitRate, err = parseUint(data.BitRate)
+	if err != nil {
+		return format, fmt.Errorf("parsing bit rate: %w", err)
+	}
+
+	size, err := parseUint(data.Size)
+	if err != nil {
+		return format, fmt.Errorf("parsing size: %w", err)
+	}
+	format.Size = int64(size)
+
+	return format, nil
+}
+
+func parseStream(data jsonStream) (stream Stream, err error) {
+	stream = Stream{
+		Index:       data.Index,
+		Type:        StreamType(data.CodecType),
+		Codec:       data.CodecName,
+		Tags:        data.Tags,
+		Width:       data.Width,
+		Height:      data.Height,
+		PixelFormat: data.PixFmt,
+		Channels:    data.Channels,
+	}
+
+	stream.Duration, err = parseDuration(data.Duration)
+	if err != nil {
+		return stream, fmt.Errorf("parsing duration: %w", err)
+	}
+
+	stream.BitRate, err = parseUint(data.BitRate)
+	if err != nil {
+		return stream, fmt.Errorf("parsing bit rate: %w", err)
+	}
+
+	sampleRate, err := parseUint(data.SampleRate)
+	if err != nil {
+		return stream, fmt.Errorf("parsing sample rate: %w", err)
+	}
+	stream.SampleRate = uint(sampleRate)
+
+	stream.FrameRate, err = parseFrameRate(data.AvgFrameRate)
+	if err != nil {
+		return stream, fmt.Errorf("parsing average frame rate: %w", err)
+	}
+	if stream.FrameRate == 0 {
+		stream.FrameRate, err = parseFrameRate(data.RFrameRate)
+		if err != nil {
+			return stream, fmt.Errorf("parsing frame rate: %w", err)
+		}
+	}
+
+	stream.Rotation, err = parseRotation(data)
+	if err != nil {
+		return stream, fmt.Errorf("parsing rotation: %w", err)
+	}
+
+	return stream, nil
+}
+
+func parseDuration(s string) (duration time.Duration, err error) {
+	if s == "" || s == "N/A" {
+		return 0, nil
+	}
+
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func parseUint(s string) (n uint64, err error) {
+	if s == "" || s == "N/A" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+var ErrFrameRateMalformed = errors.New("frame rate is malformed")
+
+// parseFrameRate parses a frame rate in the form "30000/1001",
+// returning 0 for the undefined frame rate "0/0".
+func parseFrameRate(s string) (frameRate float64, err error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	numeratorString, denominatorString, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrFrameRateMalformed, s)
+	}
+
+	numerator, err := strconv.ParseUint(numeratorString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing numerator: %w", err)
+	}
+
+	denominator, err := strconv.ParseUint(denominatorString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing denominator: %w", err)
+	} else if denominator == 0 {
+		return 0, nil
+	}
+
+	return float64(numerator) / float64(denominator), nil
+}
+
+// parseRotation returns the clockwise display rotation in degrees,
+// in the range [0, 360). It uses the `rotate` tag set by older ffprobe
+// versions, or the display matrix side data of newer ffprobe versions,
+// whose rotation is counter-clockwise.
+func parseRotation(data jsonStream) (rotation int, err error) {
+	if rotateTag, ok := data.Tags["rotate"]; ok {
+		rotation, err = strconv.Atoi(rotateTag)
+		if err != nil {
+			return 0, err
+		}
+		return normalizeRotation(rotation), nil
+	}
+
+	for _, sideData := range data.SideDataList {
+		if sideData.SideDataType == "Display Matrix" {
+			rotation = -int(math.Round(sideData.Rotation))
+			return normalizeRotation(rotation), nil
+		}
+	}
+
+	return 0, nil
+}
+
+func normalizeRotation(rotation int) int {
+	const fullTurn = 360
+	rotation %= fullTurn
+	if rotation < 0 {
+		rotation += fullTurn
+	}
+	return rotation
+}
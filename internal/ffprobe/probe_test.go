@@ -0,0 +1,203 @@
+package ffprobe
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/qdm12/tinier/internal/cmd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(string) {}
+
+func Test_FFProbe_Probe(t *testing.T) {
+	t.Parallel()
+
+	errDummy := errors.New("dummy")
+
+	testCases := map[string]struct {
+		stdout     string
+		stderr     string
+		runErr     error
+		info       Info
+		errMessage string
+	}{
+		"video file": {
+			stdout: `{
+    "streams": [
+        {
+            "index": 0,
+            "codec_name": "h264",
+            "codec_type": "video",
+            "width": 1920,
+            "height": 1080,
+            "pix_fmt": "yuv420p",
+            "r_frame_rate": "30/1",
+            "avg_frame_rate": "30000/1001",
+            "duration": "10.010000",
+            "bit_rate": "4000000",
+            "side_data_list": [
+                {
+                    "side_data_type": "Display Matrix",
+                    "rotation": -90
+                }
+            ]
+        },
+        {
+            "index": 1,
+            "codec_name": "aac",
+            "codec_type": "audio",
+            "sample_rate": "48000",
+            "channels": 2,
+            "r_frame_rate": "0/0",
+            "avg_frame_rate": "0/0",
+            "duration": "10.005333",
+            "bit_rate": "128000",
+            "tags": {
+                "language": "eng"
+            }
+        }
+    ],
+    "format": {
+        "format_name": "mov,mp4,m4a,3gp,3g2,mj2",
+        "duration": "10.010000",
+        "size": "5165000",
+        "bit_rate": "4127872",
+        "tags": {
+            "creation_time": "2023-01-01T00:00:00.000000Z"
+        }
+    }
+}`,
+			info: Info{
+				Format: Format{
+					Name:     "mov,mp4,m4a,3gp,3g2,mj2",
+					Duration: 10010 * time.Millisecond,
+					BitRate:  4127872,
+					Size:     5165000,
+					Tags:     map[string]string{"creation_time": "2023-01-01T00:00:00.000000Z"},
+				},
+				Streams: []Stream{{
+					Index:       0,
+					Type:        StreamTypeVideo,
+					Codec:       "h264",
+					Duration:    10010 * time.Millisecond,
+					BitRate:     4000000,
+					Width:       1920,
+					Height:      1080,
+					FrameRate:   30000.0 / 1001,
+					Rotation:    90,
+					PixelFormat: "yuv420p",
+				}, {
+					Index:      1,
+					Type:       StreamTypeAudio,
+					Codec:      "aac",
+					Duration:   10005333 * time.Microsecond,
+					BitRate:    128000,
+					Tags:       map[string]string{"language": "eng"},
+					Channels:   2,
+					SampleRate: 48000,
+				}},
+			},
+		},
+		"image file with rotate tag": {
+			stdout: `{
+    "streams": [
+        {
+            "index": 0,
+            "codec_name": "png",
+            "codec_type": "video",
+            "width": 640,
+            "height": 480,
+            "pix_fmt": "rgba",
+            "r_frame_rate": "25/1",
+            "avg_frame_rate": "0/0",
+            "tags": {
+                "rotate": "-90"
+            }
+        }
+    ],
+    "format": {
+        "format_name": "png_pipe",
+        "duration": "N/A",
+        "size": "1000",
+        "bit_rate": "N/A"
+    }
+}`,
+			info: Info{
+				Format: Format{Name: "png_pipe", Size: 1000},
+				Streams: []Stream{{
+					Type:        StreamTypeVideo,
+					Codec:       "png",
+					Tags:        map[string]string{"rotate": "-90"},
+					Width:       640,
+					Height:      480,
+					FrameRate:   25,
+					Rotation:    270,
+					PixelFormat: "rgba",
+				}},
+			},
+		},
+		"warning on standard error": {
+			stdout: `{"streams": [], "format": {"format_name": "mp3"}}`,
+			stderr: "[mp3 @ 0x0] Estimating duration from bitrate, this may be inaccurate",
+			info: Info{
+				Format:  Format{Name: "mp3"},
+				Streams: []Stream{},
+			},
+		},
+		"run error": {
+			stderr:     "input.mp4: Invalid data found when processing input",
+			runErr:     errDummy,
+			errMessage: "failed FFProbe probe: input.mp4: Invalid data found when processing input",
+		},
+		"malformed output": {
+			stdout:     "{",
+			errMessage: "parsing ffprobe output: decoding JSON: unexpected end of JSON input",
+		},
+		"malformed frame rate": {
+			stdout: `{"streams": [{"index": 3, "avg_frame_rate": "30"}]}`,
+			errMessage: "parsing ffprobe output: parsing stream 3: " +
+				"parsing average frame rate: frame rate is malformed: 30",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctrl := gomock.NewController(t)
+
+			runner := NewMockRunner(ctrl)
+			runner.EXPECT().Stream(gomock.Any(), gomock.Any(), nil).
+				DoAndReturn(func(execCmd cmd.ExecCmd,
+					onStdoutLine, _ func(line string)) (string, error) {
+					assert.Equal(t, "/bin/ffprobe -hide_banner -loglevel error "+
+						"-print_format json -show_format -show_streams input.mp4",
+						execCmd.(interface{ String() string }).String())
+					for _, line := range strings.Split(testCase.stdout, "\n") {
+						onStdoutLine(line)
+					}
+					return testCase.stderr, testCase.runErr
+				})
+
+			ffprobe := New(runner, "/bin/ffprobe", noopLogger{})
+
+			info, err := ffprobe.Probe(context.Background(), "input.mp4")
+
+			if testCase.errMessage != "" {
+				require.Error(t, err)
+				assert.Equal(t, testCase.errMessage, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.info, info)
+		})
+	}
+}
@@ -0,0 +1,288 @@
+// Package journal implements a persistent JSON lines journal
+// recording the outcome of each processed input file, such that
+// an interrupted run can be resumed.
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filename is the journal file name, placed in the output directory.
+const Filename = ".tinier-journal.jsonl"
+
+type Status string
+
+const (
+	StatusDone   Status = "done"
+	StatusFailed Status = "failed"
+)
+
+// Input identifies an input file in a certain state, to be processed
+// with certain settings.
+type Input struct {
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"mod_time"`
+	SettingsHash string    `json:"settings_hash"`
+}
+
+// Entry is a single line of the journal.
+type Entry struct {
+	Input
+	Status  Status    `json:"status"`
+	Outcome string    `json:"outcome,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Journal is a journal of processed input files, safe for
+// concurrent use.
+type Journal struct {
+	file    *os.File
+	encoder *json.Encoder
+	// entries maps input paths to their last recorded entry.
+	entries map[string]Entry
+	mutex   sync.Mutex
+}
+
+// Open opens the journal file at the given path, creating it if it
+// does not exist. Existing entries are loaded and the file is compacted
+// to only keep the last entry for each input path.
+func Open(path string) (journal *Journal, err error) {
+	entries, err := readEntries(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading entries: %w", err)
+	}
+
+	err = writeEntries(path, entries)
+	if err != nil {
+		return nil, fmt.Errorf("compacting journal: %w", err)
+	}
+
+	const perms os.FileMode = 0600
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, perms)
+	if err != nil {
+		return nil, fmt.Errorf("opening journal file: %w", err)
+	}
+
+	return &Journal{
+		file:    file,
+		encoder: json.NewEncoder(file),
+		entries: entries,
+	}, nil
+}
+
+// Read reads the journal file at the given path without modifying
+// it or creating it. The journal returned cannot record entries and
+// does not need to be closed.
+func Read(path string) (journal *Journal, err error) {
+	entries, err := readEntries(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading entries: %w", err)
+	}
+
+	return &Journal{
+		entries: entries,
+	}, nil
+}
+
+// Check returns the journal input for the input file path and settings
+// fingerprint given. It also returns done as true if the input was
+// already processed successfully, with the same settings and without
+// any file size or modification time change. It returns stale as true if
+// the input was previously recorded but either failed, its file changed
+// or its settings changed.
+func (j *Journal) Check(inputPath, settingsFingerprint string) (
+	input Input, done, stale bool, err error) {
+	fileInfo, err := os.Stat(inputPath)
+	if err != nil {
+		return input, false, false, fmt.Errorf("getting input file information: %w", err)
+	}
+
+	input = Input{
+		Path:         inputPath,
+		Size:         fileInfo.Size(),
+		ModTime:      fileInfo.ModTime(),
+		SettingsHash: hash(settingsFingerprint),
+	}
+
+	j.mutex.Lock()
+	entry, found := j.entries[inputPath]
+	j.mutex.Unlock()
+
+	switch {
+	case !found:
+		return input, false, false, nil
+	case entry.Status == StatusDone &&
+		entry.SettingsHash == input.SettingsHash &&
+		entry.Size == input.Size &&
+		entry.ModTime.Equal(input.ModTime):
+		return input, true, false, nil
+	default:
+		return input, false, true, nil
+	}
+}
+
+var ErrReadOnly = errors.New("journal is read only")
+
+// Record appends an entry for the input given to the journal file,
+// with a failed status if runErr is not nil.
+func (j *Journal) Record(input Input, outcome string, runErr error) (err error) {
+	if j.file == nil {
+		return ErrReadOnly
+	}
+
+	entry := Entry{
+		Input:   input,
+		Status:  StatusDone,
+		Outcome: outcome,
+		Time:    time.Now(),
+	}
+	if runErr != nil {
+		entry.Status = StatusFailed
+		entry.Error = truncateError(runErr.Error())
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	err = j.encoder.Encode(entry)
+	if err != nil {
+		return fmt.Errorf("writing journal entry: %w", err)
+	}
+	j.entries[input.Path] = entry
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() (err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// maxErrorLength is the maximum length of the error message
+// of a journal entry, beyond which the message is truncated.
+const maxErrorLength = 4096
+
+func truncateError(message string) string {
+	if len(message) <= maxErrorLength {
+		return message
+	}
+	const suffix = "..."
+	message = message[:maxErrorLength-len(suffix)]
+	return strings.ToValidUTF8(message, "") + suffix
+}
+
+func hash(settings string) string {
+	digest := sha256.Sum256([]byte(settings))
+	return hex.EncodeToString(digest[:])
+}
+
+func readEntries(path string) (entries map[string]Entry, err error) {
+	entries = make(map[string]Entry)
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return entries, nil
+		}
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, tooLong, err := readLine(reader)
+		if !tooLong && len(line) > 0 {
+			var entry Entry
+			// A line can be partially written if the program
+			// was killed, so ignore it if it cannot be decoded.
+			if json.Unmarshal(line, &entry) == nil {
+				entries[entry.Path] = entry
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("reading journal file: %w", err)
+		}
+	}
+
+	return entries, file.Close()
+}
+
+// maxLineLength is the maximum length of a journal line,
+// beyond which the line is ignored.
+const maxLineLength = 1024 * 1024
+
+// readLine reads a line from the reader given, and returns tooLong
+// as true without the line if it is longer than maxLineLength.
+func readLine(reader *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		fragment, isPrefix, err := reader.ReadLine()
+		if !tooLong {
+			line = append(line, fragment...)
+			if len(line) > maxLineLength {
+				line, tooLong = nil, true
+			}
+		}
+		if err != nil || !isPrefix {
+			return line, tooLong, err
+		}
+	}
+}
+
+func writeEntries(path string, entries map[string]Entry) (err error) {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	const perms os.FileMode = 0600
+	tempPath := filepath.Join(filepath.Dir(path), "tmp_"+filepath.Base(path))
+	file, err := os.OpenFile(tempPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, perms)
+	if err != nil {
+		return fmt.Errorf("opening temporary journal file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		err = encoder.Encode(entry)
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(tempPath)
+			return fmt.Errorf("writing journal entry: %w", err)
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("flushing journal entries: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("closing temporary journal file: %w", err)
+	}
+
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,144 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Journal(t *testing.T) {
+	t.Parallel()
+
+	dirPath := t.TempDir()
+	journalPath := filepath.Join(dirPath, Filename)
+	doneInputPath := filepath.Join(dirPath, "done.jpg")
+	failedInputPath := filepath.Join(dirPath, "failed.jpg")
+	changedInputPath := filepath.Join(dirPath, "changed.jpg")
+	for _, path := range []string{doneInputPath, failedInputPath, changedInputPath} {
+		err := os.WriteFile(path, []byte{1}, 0600)
+		require.NoError(t, err)
+	}
+
+	journal, err := Open(journalPath)
+	require.NoError(t, err)
+
+	record := func(inputPath string, runErr error) {
+		t.Helper()
+		input, _, _, err := journal.Check(inputPath, "settings")
+		require.NoError(t, err)
+		err = journal.Record(input, "", runErr)
+		require.NoError(t, err)
+	}
+
+	// Record the done input twice to verify compaction
+	record(doneInputPath, errors.New("dummy"))
+	record(doneInputPath, nil)
+	record(failedInputPath, errors.New("dummy"))
+	record(changedInputPath, nil)
+	err = journal.Close()
+	require.NoError(t, err)
+
+	modTime := time.Now().Add(time.Hour)
+	err = os.Chtimes(changedInputPath, modTime, modTime)
+	require.NoError(t, err)
+
+	journal, err = Open(journalPath)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := journal.Close()
+		assert.NoError(t, err)
+	})
+
+	data, err := os.ReadFile(journalPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	const expectedLines = 3
+	assert.Len(t, lines, expectedLines)
+
+	testCases := map[string]struct {
+		inputPath           string
+		settingsFingerprint string
+		done                bool
+		stale               bool
+	}{
+		"done": {
+			inputPath:           doneInputPath,
+			settingsFingerprint: "settings",
+			done:                true,
+		},
+		"failed": {
+			inputPath:           failedInputPath,
+			settingsFingerprint: "settings",
+			stale:               true,
+		},
+		"not recorded": {
+			inputPath:           journalPath,
+			settingsFingerprint: "settings",
+		},
+		"settings changed": {
+			inputPath:           doneInputPath,
+			settingsFingerprint: "other settings",
+			stale:               true,
+		},
+		"file changed": {
+			inputPath:           changedInputPath,
+			settingsFingerprint: "settings",
+			stale:               true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			input, done, stale, err := journal.Check(testCase.inputPath,
+				testCase.settingsFingerprint)
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.inputPath, input.Path)
+			assert.Equal(t, testCase.done, done)
+			assert.Equal(t, testCase.stale, stale)
+		})
+	}
+}
+
+func Test_Journal_longLines(t *testing.T) {
+	t.Parallel()
+
+	dirPath := t.TempDir()
+	journalPath := filepath.Join(dirPath, Filename)
+	inputPath := filepath.Join(dirPath, "input.jpg")
+	err := os.WriteFile(inputPath, []byte{1}, 0600)
+	require.NoError(t, err)
+
+	journal, err := Open(journalPath)
+	require.NoError(t, err)
+	input, _, _, err := journal.Check(inputPath, "settings")
+	require.NoError(t, err)
+	err = journal.Record(input, "", errors.New(strings.Repeat("x", 2*maxErrorLength)))
+	require.NoError(t, err)
+	err = journal.Close()
+	require.NoError(t, err)
+
+	// Prepend a line too long to be read
+	data, err := os.ReadFile(journalPath)
+	require.NoError(t, err)
+	longLine := `{"path":"` + strings.Repeat("a", maxLineLength) + `"}` + "\n"
+	err = os.WriteFile(journalPath, append([]byte(longLine), data...), 0600)
+	require.NoError(t, err)
+
+	journal, err = Read(journalPath)
+	require.NoError(t, err)
+
+	require.Len(t, journal.entries, 1)
+	entry := journal.entries[inputPath]
+	assert.Equal(t, StatusFailed, entry.Status)
+	assert.Len(t, entry.Error, maxErrorLength)
+}
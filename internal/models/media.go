@@ -0,0 +1,11 @@
+package models
+
+type MediaType string
+
+const (
+	MediaTypeImage MediaType = "image"
+	MediaTypeAudio MediaType = "audio"
+	MediaTypeVideo MediaType = "video"
+	// MediaTypeOther is for files copied as is.
+	MediaTypeOther MediaType = "other"
+)
@@ -0,0 +1,107 @@
+// Package palette finds out if an image can be stored
+// losslessly with a palette of colors.
+package palette
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // register the GIF decoder
+	_ "image/jpeg" // register the JPEG decoder
+	_ "image/png"  // register the PNG decoder
+	"os"
+)
+
+// MaxColors is the maximum number of colors of an image to store it
+// with a palette, since one of the 256 palette entries is reserved
+// for the fully transparent color.
+const MaxColors = 255
+
+// Fits returns true if the image file at the path given has at most
+// MaxColors colors, counting all fully transparent pixels as a single
+// color, and has no partially transparent pixel nor color with more
+// than 8 bits per channel, such that a palette is lossless.
+// It returns false without error for image formats other than
+// PNG, JPEG and GIF.
+func Fits(path string) (fits bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if errors.Is(err, image.ErrFormat) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("decoding image: %w", err)
+	}
+
+	return fitsImage(img), nil
+}
+
+func fitsImage(img image.Image) bool {
+	colors := make(map[color.NRGBA64]struct{}, MaxColors+1)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c, _ := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			switch c.A {
+			case 0:
+				c = color.NRGBA64{}
+			case 0xffff:
+			default: // partially transparent
+				return false
+			}
+
+			if !is8Bit(c.R) || !is8Bit(c.G) || !is8Bit(c.B) {
+				return false
+			}
+
+			colors[c] = struct{}{}
+			if len(colors) > MaxColors {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// is8Bit returns true if the 16 bit color channel value
+// given is an 8 bit value scaled to 16 bits.
+func is8Bit(value uint16) bool {
+	return value>>8 == value&0xff
+}
+
+// Transparent returns true if the image file at the path given is a
+// palette image with at least one palette color that is not fully
+// opaque, reading only the image header and palette. It returns
+// false without error for images without palette and for image
+// formats other than PNG, JPEG and GIF.
+func Transparent(path string) (transparent bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if errors.Is(err, image.ErrFormat) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("decoding image configuration: %w", err)
+	}
+
+	colorPalette, ok := config.ColorModel.(color.Palette)
+	if !ok {
+		return false, nil
+	}
+	for _, paletteColor := range colorPalette {
+		_, _, _, alpha := paletteColor.RGBA()
+		if alpha != 0xffff {
+			return true, nil
+		}
+	}
+	return false, nil
+}
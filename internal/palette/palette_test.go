@@ -0,0 +1,161 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fits(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		image func() image.Image
+		data  []byte
+		fits  bool
+	}{
+		"few colors": {
+			image: func() image.Image {
+				img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+				for x := 0; x < 16; x++ {
+					img.Set(x, 0, color.NRGBA{R: uint8(x), A: 0xff})
+				}
+				return img
+			},
+			fits: true,
+		},
+		"fully transparent pixels": {
+			image: func() image.Image {
+				img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+				for x := 0; x < 16; x++ {
+					img.Set(x, 0, color.NRGBA{R: uint8(x)}) // all transparent
+				}
+				img.Set(0, 1, color.NRGBA{G: 1, A: 0xff})
+				return img
+			},
+			fits: true,
+		},
+		"too many colors": {
+			image: func() image.Image {
+				img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+				for x := 0; x < 16; x++ {
+					for y := 0; y < 16; y++ {
+						img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), A: 0xff})
+					}
+				}
+				return img
+			},
+		},
+		"partially transparent pixel": {
+			image: func() image.Image {
+				img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+				img.Set(0, 0, color.NRGBA{A: 0x80})
+				return img
+			},
+		},
+		"16 bit color": {
+			image: func() image.Image {
+				img := image.NewNRGBA64(image.Rect(0, 0, 16, 16))
+				img.Set(0, 0, color.NRGBA64{R: 0x1234, A: 0xffff})
+				return img
+			},
+		},
+		"unsupported format": {
+			data: []byte("not an image"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "image.png")
+			const perms os.FileMode = 0600
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, perms)
+			require.NoError(t, err)
+			if testCase.image != nil {
+				err = png.Encode(file, testCase.image())
+			} else {
+				_, err = file.Write(testCase.data)
+			}
+			require.NoError(t, err)
+			err = file.Close()
+			require.NoError(t, err)
+
+			fits, err := Fits(path)
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.fits, fits)
+		})
+	}
+}
+
+func Test_Transparent(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		image       func() image.Image
+		data        []byte
+		transparent bool
+	}{
+		"transparent palette": {
+			image: func() image.Image {
+				colorPalette := color.Palette{
+					color.NRGBA{},
+					color.NRGBA{R: 0xff, A: 0xff},
+				}
+				return image.NewPaletted(image.Rect(0, 0, 16, 16), colorPalette)
+			},
+			transparent: true,
+		},
+		"opaque palette": {
+			image: func() image.Image {
+				colorPalette := color.Palette{
+					color.NRGBA{A: 0xff},
+					color.NRGBA{R: 0xff, A: 0xff},
+				}
+				return image.NewPaletted(image.Rect(0, 0, 16, 16), colorPalette)
+			},
+		},
+		"no palette": {
+			image: func() image.Image {
+				return image.NewNRGBA(image.Rect(0, 0, 16, 16))
+			},
+		},
+		"unsupported format": {
+			data: []byte("not an image"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "image.png")
+			const perms os.FileMode = 0600
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, perms)
+			require.NoError(t, err)
+			if testCase.image != nil {
+				err = png.Encode(file, testCase.image())
+			} else {
+				_, err = file.Write(testCase.data)
+			}
+			require.NoError(t, err)
+			err = file.Close()
+			require.NoError(t, err)
+
+			transparent, err := Transparent(path)
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.transparent, transparent)
+		})
+	}
+}
@@ -31,13 +31,41 @@ func InputToOutput(inputPath, outputDirPath, outputExt string) (outputTempPath,
 	return outputTempPath, outputPath
 }
 
+// InPlace returns a temporary output path and a final output path for a given
+// input path, both in the directory of the input path.
+// `outputPath` = <path to `inputPath`>/<input_filename>(.outExt)
+// `outputTempPath` = <path to `inputPath`>/tmp_<input_filename>(.outExt).
+func InPlace(inputPath, outputExt string) (outputTempPath, outputPath string) {
+	outputPath = filepath.Clean(inputPath)
+	if outputExt != "" {
+		outputPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + outputExt
+	}
+	outputTempPath = filepath.Join(filepath.Dir(outputPath), "tmp_"+filepath.Base(outputPath))
+	return outputTempPath, outputPath
+}
+
 func Walk(rootDir string, imageExtensions, audioExtensions, videoExtensions []string) (
 	imagePaths, audioPaths, videoPaths, otherPaths []string, err error) {
+	var filePaths []string
 	err = filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
+		} else if d.IsDir() { // ignore directories
+			return nil
 		}
+		filePaths = append(filePaths, path)
+		return nil
+	})
+	imagePaths, audioPaths, videoPaths, otherPaths = Split(filePaths,
+		imageExtensions, audioExtensions, videoExtensions)
+	return imagePaths, audioPaths, videoPaths, otherPaths, err
+}
 
+// Split splits the file paths given into image, audio, video and
+// other file paths, depending on their file extension.
+func Split(filePaths []string, imageExtensions, audioExtensions, videoExtensions []string) (
+	imagePaths, audioPaths, videoPaths, otherPaths []string) {
+	for _, path := range filePaths {
 		loweredPath := strings.ToLower(path) // for extension purpose, we lower case everything
 		switch {
 		case suffixIsOneOf(loweredPath, imageExtensions...):
@@ -46,13 +74,11 @@ func Walk(rootDir string, imageExtensions, audioExtensions, videoExtensions []st
 			audioPaths = append(audioPaths, path)
 		case suffixIsOneOf(loweredPath, videoExtensions...):
 			videoPaths = append(videoPaths, path)
-		case d.IsDir(): // ignore directories
 		default:
 			otherPaths = append(otherPaths, path)
 		}
-		return nil
-	})
-	return imagePaths, audioPaths, videoPaths, otherPaths, err
+	}
+	return imagePaths, audioPaths, videoPaths, otherPaths
 }
 
 func suffixIsOneOf(s string, suffixes ...string) (ok bool) {
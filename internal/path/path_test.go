@@ -61,3 +61,43 @@ func Test_InputToOutput(t *testing.T) {
 		})
 	}
 }
+
+func Test_InPlace(t *testing.T) {
+	t.Parallel()
+	testCases := map[string]struct {
+		inputPath      string
+		outExt         string
+		outputTempPath string
+		outputPath     string
+	}{
+		"same extension": {
+			inputPath:      `input/100andro/mov_0017.mp4`,
+			outputTempPath: `input/100andro/tmp_mov_0017.mp4`,
+			outputPath:     `input/100andro/mov_0017.mp4`,
+		},
+		"output extension set": {
+			inputPath:      `input/100andro/mov_0017.mp4`,
+			outExt:         ".mkv",
+			outputTempPath: `input/100andro/tmp_mov_0017.mkv`,
+			outputPath:     `input/100andro/mov_0017.mkv`,
+		},
+		"file at current path": {
+			inputPath:      `img.png`,
+			outExt:         ".jpg",
+			outputTempPath: `tmp_img.jpg`,
+			outputPath:     `img.jpg`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			outputTempPath, outputPath := InPlace(testCase.inputPath, testCase.outExt)
+
+			assert.Equal(t, testCase.outputTempPath, outputTempPath)
+			assert.Equal(t, testCase.outputPath, outputPath)
+		})
+	}
+}
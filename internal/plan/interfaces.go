@@ -0,0 +1,17 @@
+package plan
+
+import (
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/journal"
+	"github.com/qdm12/tinier/internal/models"
+)
+
+type Resolver interface {
+	Resolve(filePath string, mediaType models.MediaType) (
+		settings config.Settings, err error)
+}
+
+type Checker interface {
+	Check(inputPath, settingsFingerprint string) (
+		input journal.Input, done, stale bool, err error)
+}
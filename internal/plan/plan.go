@@ -0,0 +1,239 @@
+// Package plan classifies input files according to what a run
+// would do with them, without doing it.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/qdm12/tinier/internal/path"
+)
+
+type Action string
+
+const (
+	// ActionConvert is for files to be converted to a new output file.
+	ActionConvert Action = "convert"
+	// ActionCopy is for files to be copied to a new output file.
+	ActionCopy Action = "copy"
+	// ActionOverwrite is for files to be converted or copied,
+	// overwriting an existing output file.
+	ActionOverwrite Action = "overwrite"
+	// ActionSkipExisting is for files skipped since their
+	// output file already exists.
+	ActionSkipExisting Action = "skip_existing"
+	// ActionSkipDone is for files skipped since the journal records
+	// them as processed, in resume mode.
+	ActionSkipDone Action = "skip_done"
+	// ActionSkipDisabled is for files skipped since their media
+	// type is set to be skipped.
+	ActionSkipDisabled Action = "skip_disabled"
+)
+
+type Item struct {
+	InputPath  string `json:"input_path"`
+	OutputPath string `json:"output_path"`
+	// AlternativeOutputPath is the output path used instead of the
+	// output path for images with an alpha channel, and for audio and
+	// video files already tiny which are copied as is. Since files are
+	// not probed, it is set for files to convert if it differs from
+	// the output path, whether or not the file is in such a case.
+	AlternativeOutputPath string           `json:"alternative_output_path,omitempty"`
+	MediaType             models.MediaType `json:"media_type"`
+	Action                Action           `json:"action"`
+}
+
+type Plan struct {
+	Items  []Item         `json:"items"`
+	Totals map[Action]int `json:"totals"`
+}
+
+// Make classifies each of the file paths given according to the
+// settings resolved for each file and to the journal checker given.
+func Make(settings config.Settings, resolver Resolver, checker Checker,
+	imagePaths, audioPaths, videoPaths, otherPaths []string) (
+	plan Plan, err error) {
+	plan.Totals = make(map[Action]int)
+
+	type group struct {
+		mediaType models.MediaType
+		paths     []string
+		skip      bool
+	}
+	groups := []group{
+		{models.MediaTypeOther, otherPaths, *settings.InPlace},
+		{models.MediaTypeAudio, audioPaths, *settings.Audio.Skip},
+		{models.MediaTypeImage, imagePaths, *settings.Image.Skip},
+		{models.MediaTypeVideo, videoPaths, *settings.Video.Skip},
+	}
+
+	for _, group := range groups {
+		for _, inputPath := range group.paths {
+			item, err := makeItem(resolver, checker, inputPath,
+				group.mediaType, group.skip)
+			if err != nil {
+				return plan, fmt.Errorf("classifying %s: %w", inputPath, err)
+			}
+			plan.Items = append(plan.Items, item)
+			plan.Totals[item.Action]++
+		}
+	}
+
+	return plan, nil
+}
+
+func makeItem(resolver Resolver, checker Checker, inputPath string,
+	mediaType models.MediaType, skip bool) (item Item, err error) {
+	settings, err := resolver.Resolve(inputPath, mediaType)
+	if err != nil {
+		return item, fmt.Errorf("resolving directory settings: %w", err)
+	}
+
+	outputExtension, alternativeExtension, hasAlternative := outputExtensions(
+		settings, mediaType)
+	outputPath := makeOutputPath(settings, inputPath, outputExtension)
+
+	item = Item{
+		InputPath:  inputPath,
+		OutputPath: outputPath,
+		MediaType:  mediaType,
+	}
+
+	if skip {
+		item.Action = ActionSkipDisabled
+		return item, nil
+	}
+
+	item.Action, err = classify(settings, checker, inputPath, outputPath,
+		mediaType, settings.Fingerprint(mediaType))
+	if err != nil {
+		return item, err
+	}
+
+	if hasAlternative &&
+		(item.Action == ActionConvert || item.Action == ActionOverwrite) {
+		alternativeOutputPath := makeOutputPath(settings, inputPath, alternativeExtension)
+		if alternativeOutputPath != outputPath {
+			item.AlternativeOutputPath = alternativeOutputPath
+		}
+	}
+	return item, nil
+}
+
+// outputExtensions returns the output file extension for the media
+// type given, and an alternative output file extension with
+// hasAlternative set to true for images with an alpha channel and for
+// already tiny audio and video files, mirroring what a run does once
+// files are probed. An empty extension keeps the input file extension.
+func outputExtensions(settings config.Settings, mediaType models.MediaType) (
+	outputExtension, alternativeExtension string, hasAlternative bool) {
+	switch mediaType {
+	case models.MediaTypeImage:
+		if settings.Image.AlphaCodec == config.AlphaCodecNone {
+			return settings.Image.OutputExtension, "", false
+		}
+		return settings.Image.OutputExtension, settings.Image.AlphaOutputExtension, true
+	case models.MediaTypeAudio:
+		return settings.Audio.OutputExtension, "", true
+	case models.MediaTypeVideo:
+		return settings.Video.OutputExtension, "", true
+	default:
+		return "", "", false
+	}
+}
+
+func makeOutputPath(settings config.Settings, inputPath, outputExtension string) (
+	outputPath string) {
+	if *settings.InPlace {
+		_, outputPath = path.InPlace(inputPath, outputExtension)
+		return outputPath
+	}
+	_, outputPath = path.InputToOutput(inputPath,
+		settings.OutputDirPath, outputExtension)
+	return outputPath
+}
+
+func classify(settings config.Settings, checker Checker,
+	inputPath, outputPath string, mediaType models.MediaType,
+	settingsFingerprint string) (action Action, err error) {
+	// In in-place mode, an existing file at another output path
+	// is another original file, which is never replaced.
+	override := outputPath == inputPath ||
+		(*settings.OverrideOutput && !*settings.InPlace)
+	if *settings.Resume || *settings.InPlace {
+		_, done, stale, err := checker.Check(inputPath, settingsFingerprint)
+		if err != nil {
+			return "", fmt.Errorf("checking journal: %w", err)
+		} else if done {
+			return ActionSkipDone, nil
+		}
+		override = override || (stale && !*settings.InPlace)
+	}
+
+	exists, err := path.DoesFileExist(outputPath)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case exists && override:
+		return ActionOverwrite, nil
+	case exists:
+		return ActionSkipExisting, nil
+	case mediaType == models.MediaTypeOther:
+		return ActionCopy, nil
+	default:
+		return ActionConvert, nil
+	}
+}
+
+// WriteText writes the plan as human readable lines to the writer.
+func (p Plan) WriteText(w io.Writer) {
+	for _, item := range p.Items {
+		outputPath := item.OutputPath
+		if item.AlternativeOutputPath != "" {
+			outputPath += " (or " + item.AlternativeOutputPath + ")"
+		}
+		fmt.Fprintf(w, "%s %s %s ➡️  %s\n", actionToEmoji(item.Action),
+			item.Action, item.InputPath, outputPath)
+	}
+
+	actions := make([]string, 0, len(p.Totals))
+	for action := range p.Totals {
+		actions = append(actions, string(action))
+	}
+	sort.Strings(actions)
+
+	parts := make([]string, len(actions))
+	for i, action := range actions {
+		parts[i] = fmt.Sprintf("%d %s", p.Totals[Action(action)], action)
+	}
+	fmt.Fprintf(w, "📋 Plan: %d file(s): %s\n", len(p.Items), strings.Join(parts, ", "))
+}
+
+// WriteJSON writes the plan as JSON to the writer.
+func (p Plan) WriteJSON(w io.Writer) (err error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+func actionToEmoji(action Action) string {
+	switch action {
+	case ActionConvert:
+		return "🗜️ "
+	case ActionCopy:
+		return "🗄️ "
+	case ActionOverwrite:
+		return "♻️ "
+	case ActionSkipExisting, ActionSkipDone, ActionSkipDisabled:
+		return "⏭️ "
+	default:
+		panic(fmt.Sprintf("action %q not implemented", action))
+	}
+}
@@ -0,0 +1,82 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/journal"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChecker struct {
+	done map[string]bool
+}
+
+func (f *fakeChecker) Check(inputPath, _ string) (
+	input journal.Input, done, stale bool, err error) {
+	return journal.Input{Path: inputPath}, f.done[inputPath], false, nil
+}
+
+type noopResolver struct {
+	settings config.Settings
+}
+
+func (n *noopResolver) Resolve(string, models.MediaType) (
+	settings config.Settings, err error) {
+	return n.settings, nil
+}
+
+func Test_Make(t *testing.T) {
+	t.Parallel()
+
+	outputDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(outputDir, "existing.jpg"), nil, 0600)
+	require.NoError(t, err)
+
+	resume, skipAudio := true, true
+	settings := config.Settings{
+		OutputDirPath: outputDir,
+		Resume:        &resume,
+		Audio:         config.Audio{Skip: &skipAudio},
+	}
+	settings.SetDefaults()
+
+	checker := &fakeChecker{done: map[string]bool{"input/done.mp4": true}}
+
+	plan, err := Make(settings, &noopResolver{settings: settings}, checker,
+		[]string{"input/new.png", "input/existing.jpg"},
+		[]string{"input/song.mp3"},
+		[]string{"input/done.mp4", "input/clip.mov"},
+		[]string{"input/notes.txt"})
+
+	require.NoError(t, err)
+	expectedItems := []Item{
+		{InputPath: "input/notes.txt", OutputPath: filepath.Join(outputDir, "notes.txt"),
+			MediaType: models.MediaTypeOther, Action: ActionCopy},
+		{InputPath: "input/song.mp3", OutputPath: filepath.Join(outputDir, "song.opus"),
+			MediaType: models.MediaTypeAudio, Action: ActionSkipDisabled},
+		{InputPath: "input/new.png", OutputPath: filepath.Join(outputDir, "new.jpg"),
+			AlternativeOutputPath: filepath.Join(outputDir, "new.png"),
+			MediaType:             models.MediaTypeImage, Action: ActionConvert},
+		{InputPath: "input/existing.jpg", OutputPath: filepath.Join(outputDir, "existing.jpg"),
+			MediaType: models.MediaTypeImage, Action: ActionSkipExisting},
+		{InputPath: "input/done.mp4", OutputPath: filepath.Join(outputDir, "done.mp4"),
+			MediaType: models.MediaTypeVideo, Action: ActionSkipDone},
+		{InputPath: "input/clip.mov", OutputPath: filepath.Join(outputDir, "clip.mp4"),
+			AlternativeOutputPath: filepath.Join(outputDir, "clip.mov"),
+			MediaType:             models.MediaTypeVideo, Action: ActionConvert},
+	}
+	assert.Equal(t, expectedItems, plan.Items)
+	expectedTotals := map[Action]int{
+		ActionCopy:         1,
+		ActionSkipDisabled: 1,
+		ActionConvert:      2,
+		ActionSkipExisting: 1,
+		ActionSkipDone:     1,
+	}
+	assert.Equal(t, expectedTotals, plan.Totals)
+}
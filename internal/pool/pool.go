@@ -0,0 +1,39 @@
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Run calls fn for each of the inputs given, with at most `workers`
+// calls running concurrently. It stops dispatching inputs once the
+// context is canceled, and only returns once all the running calls
+// have returned.
+func Run(ctx context.Context, workers uint, inputs []string,
+	fn func(input string)) {
+	inputsCh := make(chan string)
+	var wg sync.WaitGroup
+	for i := uint(0); i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for input := range inputsCh {
+				if ctx.Err() != nil {
+					continue
+				}
+				fn(input)
+			}
+		}()
+	}
+
+dispatch:
+	for _, input := range inputs {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case inputsCh <- input:
+		}
+	}
+	close(inputsCh)
+	wg.Wait()
+}
@@ -0,0 +1,59 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("all inputs with concurrency limit", func(t *testing.T) {
+		t.Parallel()
+
+		const workers = 2
+		inputs := []string{"a", "b", "c", "d", "e"}
+
+		var mutex sync.Mutex
+		running, maxRunning := 0, 0
+		var done []string
+
+		Run(context.Background(), workers, inputs, func(input string) {
+			mutex.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mutex.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mutex.Lock()
+			running--
+			done = append(done, input)
+			mutex.Unlock()
+		})
+
+		assert.ElementsMatch(t, inputs, done)
+		assert.LessOrEqual(t, maxRunning, workers)
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		inputs := []string{"a", "b", "c"}
+
+		var done []string
+		Run(ctx, 1, inputs, func(input string) {
+			done = append(done, input)
+			cancel()
+		})
+
+		assert.Equal(t, []string{"a"}, done)
+	})
+}
@@ -0,0 +1,101 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/size"
+	"github.com/qdm12/tinier/internal/spinner"
+)
+
+// Line renders the progress of an ffmpeg conversion
+// at the end of a terminal line.
+type Line struct {
+	writer io.Writer
+	line   string
+	total  time.Duration
+}
+
+// New creates a progress line writing to the writer given,
+// prefixing the progress with the line given. The total
+// duration is the duration of the input media, and can be
+// zero if unknown.
+func New(w io.Writer, line string, total time.Duration) *Line {
+	return &Line{
+		writer: w,
+		line:   line,
+		total:  total,
+	}
+}
+
+// Update rewrites the line with the progress given.
+func (l *Line) Update(progress ffmpeg.Progress) {
+	spinner.ClearLine(l.writer)
+	fmt.Fprint(l.writer, l.line+" "+Format(progress, l.total))
+}
+
+// Clear rewrites the line without any progress.
+func (l *Line) Clear() {
+	spinner.ClearLine(l.writer)
+	fmt.Fprint(l.writer, l.line)
+}
+
+// Format formats the progress given with its percentage, speed,
+// output size so far and estimated time remaining. The percentage
+// and estimated time remaining are omitted if the total duration
+// is zero.
+func Format(progress ffmpeg.Progress, total time.Duration) string {
+	fields := make([]string, 0, 4) //nolint:gomnd
+
+	percent, ok := Percent(progress, total)
+	if ok {
+		fields = append(fields, fmt.Sprintf("%.1f%%", percent))
+	}
+
+	if progress.Speed > 0 {
+		fields = append(fields, fmt.Sprintf("%.1fx", progress.Speed))
+	}
+
+	fields = append(fields, size.BytesToHuman(progress.TotalSize))
+
+	eta, ok := ETA(progress, total)
+	if ok {
+		fields = append(fields, "ETA "+eta.Round(time.Second).String())
+	}
+
+	return strings.Join(fields, " | ")
+}
+
+// Percent returns the percentage of the total duration given
+// already converted, capped to 100. It returns false if the
+// total duration is zero.
+func Percent(progress ffmpeg.Progress, total time.Duration) (
+	percent float64, ok bool) {
+	if total <= 0 {
+		return 0, false
+	}
+	const maxPercent = 100
+	percent = maxPercent * float64(progress.OutTime) / float64(total)
+	if percent > maxPercent {
+		percent = maxPercent
+	}
+	return percent, true
+}
+
+// ETA returns the estimated time remaining to convert the total
+// duration given at the current speed. It returns false if the
+// total duration or the speed is zero.
+func ETA(progress ffmpeg.Progress, total time.Duration) (
+	eta time.Duration, ok bool) {
+	if total <= 0 || progress.Speed <= 0 {
+		return 0, false
+	}
+	remaining := total - progress.OutTime
+	if remaining < 0 {
+		remaining = 0
+	}
+	return time.Duration(float64(remaining) / progress.Speed), true
+}
@@ -0,0 +1,68 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Format(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		progress ffmpeg.Progress
+		total    time.Duration
+		s        string
+	}{
+		"empty": {
+			s: "0B",
+		},
+		"unknown total duration": {
+			progress: ffmpeg.Progress{
+				OutTime:   time.Minute,
+				Speed:     1.8,
+				TotalSize: 12 * 1024 * 1024,
+			},
+			s: "1.8x | 12MB",
+		},
+		"unknown speed": {
+			progress: ffmpeg.Progress{
+				OutTime:   time.Minute,
+				TotalSize: 1000,
+			},
+			total: 4 * time.Minute,
+			s:     "25.0% | 1000B",
+		},
+		"in progress": {
+			progress: ffmpeg.Progress{
+				OutTime:   time.Minute,
+				Speed:     1.5,
+				TotalSize: 12 * 1024 * 1024,
+			},
+			total: 4 * time.Minute,
+			s:     "25.0% | 1.5x | 12MB | ETA 2m0s",
+		},
+		"out time past total": {
+			progress: ffmpeg.Progress{
+				OutTime:   5 * time.Minute,
+				Speed:     2,
+				TotalSize: 1000,
+			},
+			total: 4 * time.Minute,
+			s:     "100.0% | 2.0x | 1000B | ETA 0s",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := Format(testCase.progress, testCase.total)
+
+			assert.Equal(t, testCase.s, s)
+		})
+	}
+}
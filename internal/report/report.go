@@ -0,0 +1,167 @@
+// Package report collects the results of processing each file
+// of a run, to write them together with the run totals as JSON.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/qdm12/tinier/internal/models"
+)
+
+type Status string
+
+const (
+	// StatusConverted is for files converted to a new output file.
+	StatusConverted Status = "converted"
+	// StatusCopied is for files copied as is to the output directory.
+	StatusCopied Status = "copied"
+	// StatusAlreadyTiny is for files copied as is to the output
+	// directory since they are already efficiently encoded.
+	StatusAlreadyTiny Status = "already_tiny"
+	// StatusSkippedExisting is for files skipped since their
+	// output file already exists.
+	StatusSkippedExisting Status = "skipped_existing"
+	// StatusSkippedDone is for files skipped since the journal
+	// records them as processed, in resume mode.
+	StatusSkippedDone Status = "skipped_done"
+	// StatusRejected is for files whose converted output was rejected
+	// since its quality score is below the quality threshold, such
+	// that the input file is used instead.
+	StatusRejected Status = "rejected"
+	// StatusFailed is for files which failed to be processed.
+	StatusFailed Status = "failed"
+)
+
+// File is the result of processing a single file.
+type File struct {
+	InputPath  string           `json:"input_path"`
+	OutputPath string           `json:"output_path,omitempty"`
+	MediaType  models.MediaType `json:"media_type"`
+	Status     Status           `json:"status"`
+	// Settings is the string of the settings used to convert the
+	// file, as used for the journal settings fingerprint.
+	Settings   string `json:"settings,omitempty"`
+	InputSize  int64  `json:"input_size,omitempty"`
+	OutputSize int64  `json:"output_size,omitempty"`
+	// Ratio is the output size divided by the input size.
+	Ratio float64 `json:"ratio,omitempty"`
+	// InputKept is true if the output was replaced by the input
+	// since the output was bigger than the input.
+	InputKept bool `json:"input_kept,omitempty"`
+	// CRF is the CRF found by the CRF search to reach the target
+	// quality, and is nil if the CRF search is disabled.
+	CRF *uint `json:"crf,omitempty"`
+	// VideoBitRate is the video bit rate in bits per second computed
+	// to reach the target size or bit rate, and is zero if the target
+	// size mode is disabled.
+	VideoBitRate uint64 `json:"video_bit_rate,omitempty"`
+	// QualityMetric is the metric of the quality score, and is empty
+	// if the quality check is disabled.
+	QualityMetric string   `json:"quality_metric,omitempty"`
+	QualityScore  *float64 `json:"quality_score,omitempty"`
+	// QualityRetries is the number of times the file was converted
+	// again at a higher quality since its score was too low.
+	QualityRetries uint    `json:"quality_retries,omitempty"`
+	Seconds        float64 `json:"duration_seconds"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// SetSizes sets the input and output sizes and their ratio.
+func (f *File) SetSizes(inputSize, outputSize int64) {
+	f.InputSize = inputSize
+	f.OutputSize = outputSize
+	if inputSize > 0 {
+		f.Ratio = float64(outputSize) / float64(inputSize)
+	}
+}
+
+// Totals are the aggregate totals of a run.
+type Totals struct {
+	Files      int     `json:"files"`
+	Failures   int     `json:"failures"`
+	InputSize  int64   `json:"input_size"`
+	OutputSize int64   `json:"output_size"`
+	Ratio      float64 `json:"ratio,omitempty"`
+	Seconds    float64 `json:"duration_seconds"`
+}
+
+// MaxFiles is the maximum number of files kept in a report, so
+// its memory usage is bounded for long running processes such as
+// in watch mode. The oldest files are dropped first, but are still
+// counted in the totals.
+const MaxFiles = 10000
+
+// Report collects the results of processing each file of a run,
+// and is safe for concurrent use.
+type Report struct {
+	Start  time.Time `json:"start"`
+	Files  []File    `json:"files"`
+	Totals Totals    `json:"totals"`
+	// files is the number of files added, including the ones dropped.
+	files int
+	mutex sync.Mutex
+}
+
+func New() *Report {
+	return &Report{
+		Start: time.Now(),
+		Files: []File{},
+	}
+}
+
+// Add adds the result of processing a file to the report,
+// dropping the oldest file if the report has MaxFiles files.
+func (r *Report) Add(file File) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.files++
+	if len(r.Files) == MaxFiles {
+		r.Files = r.Files[1:]
+	}
+	r.Files = append(r.Files, file)
+}
+
+// WriteJSON writes the report with the totals given as JSON
+// to the writer given.
+func (r *Report) WriteJSON(w io.Writer, failures int, inputSize, outputSize int64) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Totals = Totals{
+		Files:      r.files,
+		Failures:   failures,
+		InputSize:  inputSize,
+		OutputSize: outputSize,
+		Seconds:    time.Since(r.Start).Seconds(),
+	}
+	if inputSize > 0 {
+		r.Totals.Ratio = float64(outputSize) / float64(inputSize)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteFile writes the report with the totals given as JSON
+// to the file path given, replacing any existing file.
+func (r *Report) WriteFile(path string, failures int, inputSize, outputSize int64) (err error) {
+	const perms os.FileMode = 0600
+	file, err := os.OpenFile(path, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, perms)
+	if err != nil {
+		return fmt.Errorf("opening report file: %w", err)
+	}
+
+	err = r.WriteJSON(file, failures, inputSize, outputSize)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("writing JSON report: %w", err)
+	}
+
+	return file.Close()
+}
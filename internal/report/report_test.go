@@ -0,0 +1,111 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_File_SetSizes(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		inputSize  int64
+		outputSize int64
+		file       File
+	}{
+		"zero input size": {
+			outputSize: 10,
+			file:       File{OutputSize: 10},
+		},
+		"smaller output": {
+			inputSize:  100,
+			outputSize: 25,
+			file:       File{InputSize: 100, OutputSize: 25, Ratio: 0.25},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var file File
+			file.SetSizes(testCase.inputSize, testCase.outputSize)
+
+			assert.Equal(t, testCase.file, file)
+		})
+	}
+}
+
+func Test_Report_WriteJSON(t *testing.T) {
+	t.Parallel()
+
+	report := New()
+	convertedFile := File{
+		InputPath:  "input/a.png",
+		OutputPath: "output/a.jpg",
+		MediaType:  models.MediaTypeImage,
+		Status:     StatusConverted,
+		Settings:   "ext=.jpg",
+		InputSize:  100,
+		OutputSize: 40,
+		Ratio:      0.4,
+		Seconds:    1.5,
+	}
+	report.Add(convertedFile)
+	failedFile := File{
+		InputPath: "input/b.mp4",
+		MediaType: models.MediaTypeVideo,
+		Status:    StatusFailed,
+		Error:     "failed FFMPEG conversion",
+	}
+	report.Add(failedFile)
+
+	buffer := bytes.NewBuffer(nil)
+	err := report.WriteJSON(buffer, 1, 100, 40)
+	require.NoError(t, err)
+
+	var decoded struct {
+		Files  []File `json:"files"`
+		Totals Totals `json:"totals"`
+	}
+	err = json.Unmarshal(buffer.Bytes(), &decoded)
+	require.NoError(t, err)
+
+	assert.Equal(t, []File{convertedFile, failedFile}, decoded.Files)
+	assert.Positive(t, decoded.Totals.Seconds)
+	decoded.Totals.Seconds = 0
+	expectedTotals := Totals{
+		Files:      2,
+		Failures:   1,
+		InputSize:  100,
+		OutputSize: 40,
+		Ratio:      0.4,
+	}
+	assert.Equal(t, expectedTotals, decoded.Totals)
+}
+
+func Test_Report_Add(t *testing.T) {
+	t.Parallel()
+
+	report := New()
+	const files = MaxFiles + 2
+	for i := 0; i < files; i++ {
+		report.Add(File{InputPath: fmt.Sprint(i)})
+	}
+
+	buffer := bytes.NewBuffer(nil)
+	err := report.WriteJSON(buffer, 0, 0, 0)
+	require.NoError(t, err)
+
+	require.Len(t, report.Files, MaxFiles)
+	assert.Equal(t, "2", report.Files[0].InputPath)
+	assert.Equal(t, fmt.Sprint(files-1), report.Files[MaxFiles-1].InputPath)
+	assert.Equal(t, files, report.Totals.Files)
+}
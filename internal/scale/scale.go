@@ -0,0 +1,114 @@
+package scale
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Resolution is a width and height in pixels.
+type Resolution struct {
+	Width  uint
+	Height uint
+}
+
+func (r Resolution) String() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+var ErrScaleMalformed = errors.New("scale is malformed")
+
+// Fit returns the ffmpeg scale filter value to use to scale the source
+// resolution given according to the scale value given, together with
+// the resulting resolution. The scale value is in the form `W:H`,
+// where each dimension is either a number of pixels, 0 to keep the
+// source dimension or -1 to keep the source aspect ratio.
+// If upscale is false, each dimension is capped to its source
+// dimension, such that the source is only ever downscaled.
+// If the source resolution is unknown, the scale value is returned
+// unchanged together with a zero resolution.
+func Fit(scale string, source Resolution, upscale bool) (
+	effectiveScale string, target Resolution, err error) {
+	width, height, err := parse(scale)
+	if err != nil {
+		return "", target, err
+	}
+
+	if source.Width == 0 || source.Height == 0 {
+		return scale, target, nil
+	}
+
+	if width == 0 {
+		width = int(source.Width)
+	}
+	if height == 0 {
+		height = int(source.Height)
+	}
+
+	if !upscale {
+		width = capDimension(width, source.Width)
+		height = capDimension(height, source.Height)
+	}
+
+	switch {
+	case width == -1 && height == -1:
+		target = source
+	case width == -1:
+		target.Height = uint(height)
+		target.Width = keepRatio(source.Width, target.Height, source.Height)
+	case height == -1:
+		target.Width = uint(width)
+		target.Height = keepRatio(source.Height, target.Width, source.Width)
+	default:
+		target = Resolution{Width: uint(width), Height: uint(height)}
+	}
+
+	effectiveScale = fmt.Sprintf("%d:%d", width, height)
+	return effectiveScale, target, nil
+}
+
+func parse(scale string) (width, height int, err error) {
+	widthString, heightString, ok := strings.Cut(scale, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %s", ErrScaleMalformed, scale)
+	}
+
+	width, err = parseDimension(widthString)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %s: width: %w", ErrScaleMalformed, scale, err)
+	}
+
+	height, err = parseDimension(heightString)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %s: height: %w", ErrScaleMalformed, scale, err)
+	}
+
+	return width, height, nil
+}
+
+var ErrDimensionNotValid = errors.New("dimension is not valid")
+
+func parseDimension(s string) (dimension int, err error) {
+	dimension, err = strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	} else if dimension < -1 {
+		return 0, fmt.Errorf("%w: %d", ErrDimensionNotValid, dimension)
+	}
+	return dimension, nil
+}
+
+func capDimension(dimension int, sourceDimension uint) int {
+	if dimension > int(sourceDimension) {
+		return int(sourceDimension)
+	}
+	return dimension
+}
+
+// keepRatio returns the dimension scaled such that the aspect ratio
+// is kept, rounded the same way ffmpeg does for -1 dimensions.
+func keepRatio(dimension, otherTarget, otherSource uint) uint {
+	return uint(math.Round(float64(dimension) * float64(otherTarget) / float64(otherSource)))
+}
@@ -0,0 +1,96 @@
+package scale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Fit(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		scale          string
+		source         Resolution
+		upscale        bool
+		effectiveScale string
+		target         Resolution
+		errMessage     string
+	}{
+		"malformed scale": {
+			scale:      "1280",
+			errMessage: "scale is malformed: 1280",
+		},
+		"malformed width": {
+			scale:      "-2:-1",
+			errMessage: "scale is malformed: -2:-1: width: dimension is not valid: -2",
+		},
+		"unknown source resolution": {
+			scale:          "1280:-1",
+			effectiveScale: "1280:-1",
+		},
+		"downscale width": {
+			scale:          "1280:-1",
+			source:         Resolution{Width: 1920, Height: 1080},
+			effectiveScale: "1280:-1",
+			target:         Resolution{Width: 1280, Height: 720},
+		},
+		"downscale height": {
+			scale:          "-1:720",
+			source:         Resolution{Width: 1920, Height: 1080},
+			effectiveScale: "-1:720",
+			target:         Resolution{Width: 1280, Height: 720},
+		},
+		"no upscale": {
+			scale:          "1280:-1",
+			source:         Resolution{Width: 640, Height: 480},
+			effectiveScale: "640:-1",
+			target:         Resolution{Width: 640, Height: 480},
+		},
+		"upscale": {
+			scale:          "1280:-1",
+			source:         Resolution{Width: 640, Height: 480},
+			upscale:        true,
+			effectiveScale: "1280:-1",
+			target:         Resolution{Width: 1280, Height: 960},
+		},
+		"keep source resolution": {
+			scale:          "-1:-1",
+			source:         Resolution{Width: 640, Height: 480},
+			effectiveScale: "-1:-1",
+			target:         Resolution{Width: 640, Height: 480},
+		},
+		"zero dimensions": {
+			scale:          "0:0",
+			source:         Resolution{Width: 640, Height: 480},
+			effectiveScale: "640:480",
+			target:         Resolution{Width: 640, Height: 480},
+		},
+		"both dimensions capped independently": {
+			scale:          "1280:720",
+			source:         Resolution{Width: 1920, Height: 480},
+			effectiveScale: "1280:480",
+			target:         Resolution{Width: 1280, Height: 480},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			effectiveScale, target, err := Fit(testCase.scale,
+				testCase.source, testCase.upscale)
+
+			if testCase.errMessage != "" {
+				require.Error(t, err)
+				assert.Equal(t, testCase.errMessage, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testCase.effectiveScale, effectiveScale)
+			assert.Equal(t, testCase.target, target)
+		})
+	}
+}
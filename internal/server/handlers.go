@@ -0,0 +1,286 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+var ErrContentTypeNotSupported = errors.New("content type not supported")
+
+// handleSubmit creates a job for a file uploaded as the `file` field
+// of a multipart form, or for a server-local file path given as the
+// `path` field of a JSON object.
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var inputPath, uploadDirPath string
+	switch mediaType {
+	case "multipart/form-data":
+		uploadDirPath = filepath.Join(s.uploadDirPath, id)
+		inputPath, err = s.saveUpload(w, r, id)
+		if err != nil {
+			status := http.StatusBadRequest
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeError(w, status, err)
+			return
+		}
+	case "application/json":
+		inputPath, err = s.localPath(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeError(w, http.StatusUnsupportedMediaType,
+			fmt.Errorf("%w: %q", ErrContentTypeNotSupported, mediaType))
+		return
+	}
+
+	job := newJob(s.ctx, id, inputPath, uploadDirPath)
+	err = s.enqueue(job)
+	if err != nil {
+		_ = os.RemoveAll(filepath.Join(s.uploadDirPath, id))
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, job.toJSON())
+}
+
+var (
+	ErrUploadFileMissing     = errors.New("upload file field missing")
+	ErrUploadFileNameMissing = errors.New("upload file name missing")
+)
+
+// saveUpload saves the file of the `file` field of the multipart
+// form request given to the upload directory of the job id given,
+// and returns its path.
+func (s *Server) saveUpload(w http.ResponseWriter, r *http.Request, id string) (
+	filePath string, err error) {
+	const bytesPerMB = 1024 * 1024
+	maxBytes := int64(*s.settings.MaxUploadMB) * bytesPerMB
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	multipartReader, err := r.MultipartReader()
+	if err != nil {
+		return "", fmt.Errorf("reading multipart form: %w", err)
+	}
+
+	for {
+		part, err := multipartReader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return "", ErrUploadFileMissing
+		} else if err != nil {
+			return "", fmt.Errorf("reading multipart form: %w", err)
+		}
+
+		if part.FormName() != "file" {
+			continue
+		}
+
+		fileName := part.FileName()
+		if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+			return "", ErrUploadFileNameMissing
+		}
+
+		jobDir := filepath.Join(s.uploadDirPath, id)
+		const dirPerms os.FileMode = 0700
+		err = os.MkdirAll(jobDir, dirPerms)
+		if err != nil {
+			return "", fmt.Errorf("creating upload directory: %w", err)
+		}
+
+		filePath = filepath.Join(jobDir, filepath.Base(fileName))
+		err = writeFile(filePath, part)
+		if err != nil {
+			_ = os.RemoveAll(jobDir)
+			return "", err
+		}
+		return filePath, nil
+	}
+}
+
+func writeFile(filePath string, r io.Reader) (err error) {
+	const perms os.FileMode = 0600
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perms)
+	if err != nil {
+		return fmt.Errorf("creating upload file: %w", err)
+	}
+
+	_, err = io.Copy(file, r)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("writing upload file: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("closing upload file: %w", err)
+	}
+	return nil
+}
+
+var (
+	ErrPathMissing         = errors.New("path missing")
+	ErrPathOutsideInputDir = errors.New("path is outside the input directory")
+	ErrPathNotRegularFile  = errors.New("path is not a regular file")
+)
+
+// localPath decodes the JSON object with a `path` field from the
+// reader given, and returns the path, which must be a regular file
+// in the input directory. A relative path is relative to the input
+// directory.
+func (s *Server) localPath(body io.Reader) (filePath string, err error) {
+	var request struct {
+		Path string `json:"path"`
+	}
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&request)
+	if err != nil {
+		return "", fmt.Errorf("decoding JSON body: %w", err)
+	} else if request.Path == "" {
+		return "", ErrPathMissing
+	}
+
+	filePath = request.Path
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(s.inputDirPath, filePath)
+	}
+
+	// Resolve symbolic links before checking the path is in the input
+	// directory, so a symbolic link in the input directory cannot
+	// expose a file outside of it.
+	realInputDir, err := filepath.EvalSymlinks(s.inputDirPath)
+	if err != nil {
+		return "", fmt.Errorf("resolving input directory path: %w", err)
+	}
+	realFilePath, err := filepath.EvalSymlinks(filePath)
+	if err != nil {
+		return "", fmt.Errorf("resolving file path: %w", err)
+	}
+
+	absoluteInputDir, err := filepath.Abs(realInputDir)
+	if err != nil {
+		return "", fmt.Errorf("getting absolute input directory path: %w", err)
+	}
+	absoluteFilePath, err := filepath.Abs(realFilePath)
+	if err != nil {
+		return "", fmt.Errorf("getting absolute file path: %w", err)
+	}
+
+	relativePath, err := filepath.Rel(absoluteInputDir, absoluteFilePath)
+	if err != nil || relativePath == ".." ||
+		strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrPathOutsideInputDir, request.Path)
+	}
+
+	// Use a path relative to the input directory path, so the output
+	// path and directory settings are the same as for a normal run.
+	filePath = filepath.Join(s.inputDirPath, relativePath)
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	} else if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s", ErrPathNotRegularFile, request.Path)
+	}
+
+	return filePath, nil
+}
+
+var ErrJobNotFound = errors.New("job not found")
+
+func (s *Server) handleStatus(w http.ResponseWriter, id string) {
+	job, ok := s.getJob(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrJobNotFound, id))
+		return
+	}
+	writeJSON(w, http.StatusOK, job.toJSON())
+}
+
+var ErrJobFinished = errors.New("job is already finished")
+
+func (s *Server) handleCancel(w http.ResponseWriter, id string) {
+	job, ok := s.getJob(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrJobNotFound, id))
+		return
+	}
+
+	if !job.stop() {
+		writeError(w, http.StatusConflict, fmt.Errorf("%w: %s", ErrJobFinished, id))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job.toJSON())
+}
+
+var (
+	ErrJobNotDone         = errors.New("job is not done")
+	ErrOutputNotAvailable = errors.New("output file not available")
+)
+
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request, id string) {
+	job, ok := s.getJob(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrJobNotFound, id))
+		return
+	}
+
+	outputPath, ok := job.outputPath()
+	if !ok {
+		writeError(w, http.StatusConflict, fmt.Errorf("%w: %s", ErrJobNotDone, id))
+		return
+	} else if outputPath == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrOutputNotAvailable, id))
+		return
+	}
+
+	file, err := os.Open(outputPath)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %w", ErrOutputNotAvailable, err))
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	fileName := filepath.Base(outputPath)
+	w.Header().Set("Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	http.ServeContent(w, r, fileName, stat.ModTime(), file)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
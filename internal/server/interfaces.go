@@ -0,0 +1,16 @@
+package server
+
+import (
+	"context"
+
+	"github.com/qdm12/tinier/internal/report"
+)
+
+type Processor interface {
+	ProcessFile(ctx context.Context, filePath string) (result report.File, err error)
+}
+
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
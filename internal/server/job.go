@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qdm12/tinier/internal/report"
+)
+
+type Status string
+
+const (
+	StatusQueued   Status = "queued"
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusFailed   Status = "failed"
+	StatusCanceled Status = "canceled"
+)
+
+// job is a file to process, and is safe for concurrent use.
+type job struct {
+	id        string
+	inputPath string
+	// uploadDirPath is the directory of the uploaded input file,
+	// and is empty for a server-local input file.
+	uploadDirPath string
+	ctx           context.Context //nolint:containedctx
+	cancel        context.CancelFunc
+	mutex         sync.Mutex
+	status        Status
+	result        report.File
+	err           error
+	finishedAt    time.Time
+}
+
+func newJob(parent context.Context, id, inputPath, uploadDirPath string) *job {
+	ctx, cancel := context.WithCancel(parent)
+	return &job{
+		id:            id,
+		inputPath:     inputPath,
+		uploadDirPath: uploadDirPath,
+		ctx:           ctx,
+		cancel:        cancel,
+		status:        StatusQueued,
+	}
+}
+
+func newJobID() (id string, err error) {
+	const idLength = 8
+	b := make([]byte, idLength)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("generating random job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// start sets the job as running, and returns false
+// if the job was canceled before it started.
+func (j *job) start() (ok bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.status != StatusQueued {
+		return false
+	}
+	j.status = StatusRunning
+	return true
+}
+
+// finish sets the job status depending on the result
+// and error given, and whether the job was canceled.
+func (j *job) finish(result report.File, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.result = result
+	j.err = err
+	switch {
+	case j.ctx.Err() != nil:
+		j.status = StatusCanceled
+	case err != nil:
+		j.status = StatusFailed
+	default:
+		j.status = StatusDone
+	}
+	j.finishedAt = time.Now()
+	j.cancel() // release context resources
+}
+
+// stop cancels the job, and returns false if the job
+// is already finished.
+func (j *job) stop() (ok bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	switch j.status {
+	case StatusQueued:
+		j.status = StatusCanceled
+		j.finishedAt = time.Now()
+	case StatusRunning:
+	default:
+		return false
+	}
+	j.cancel()
+	return true
+}
+
+// jobJSON is the JSON representation of a job.
+type jobJSON struct {
+	ID        string       `json:"id"`
+	Status    Status       `json:"status"`
+	InputPath string       `json:"input_path"`
+	Result    *report.File `json:"result,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+func (j *job) toJSON() (data jobJSON) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	data = jobJSON{
+		ID:        j.id,
+		Status:    j.status,
+		InputPath: j.inputPath,
+	}
+	switch j.status {
+	case StatusDone, StatusFailed:
+		result := j.result
+		data.Result = &result
+	}
+	if j.err != nil {
+		data.Error = j.err.Error()
+	}
+	return data
+}
+
+// outputPath returns the output path of the job,
+// and false if the job is not done.
+func (j *job) outputPath() (outputPath string, ok bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.status != StatusDone {
+		return "", false
+	}
+	return j.result.OutputPath, true
+}
+
+// resultOutputPath returns the output path of the job result,
+// whatever the job status.
+func (j *job) resultOutputPath() (outputPath string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.result.OutputPath
+}
+
+// expired returns true if the job finished more than
+// the retention duration given before the time given.
+func (j *job) expired(now time.Time, retention time.Duration) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return !j.finishedAt.IsZero() && now.Sub(j.finishedAt) > retention
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/qdm12/tinier/internal/server (interfaces: Processor)
+
+// Package server is a generated GoMock package.
+package server
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	report "github.com/qdm12/tinier/internal/report"
+)
+
+// MockProcessor is a mock of Processor interface.
+type MockProcessor struct {
+	ctrl     *gomock.Controller
+	recorder *MockProcessorMockRecorder
+}
+
+// MockProcessorMockRecorder is the mock recorder for MockProcessor.
+type MockProcessorMockRecorder struct {
+	mock *MockProcessor
+}
+
+// NewMockProcessor creates a new mock instance.
+func NewMockProcessor(ctrl *gomock.Controller) *MockProcessor {
+	mock := &MockProcessor{ctrl: ctrl}
+	mock.recorder = &MockProcessorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProcessor) EXPECT() *MockProcessorMockRecorder {
+	return m.recorder
+}
+
+// ProcessFile mocks base method.
+func (m *MockProcessor) ProcessFile(arg0 context.Context, arg1 string) (report.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessFile", arg0, arg1)
+	ret0, _ := ret[0].(report.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessFile indicates an expected call of ProcessFile.
+func (mr *MockProcessorMockRecorder) ProcessFile(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessFile", reflect.TypeOf((*MockProcessor)(nil).ProcessFile), arg0, arg1)
+}
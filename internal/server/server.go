@@ -0,0 +1,283 @@
+// Package server implements an HTTP API to submit files to process
+// as jobs, poll their status and download their result.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qdm12/tinier/internal/config"
+)
+
+// Server is an HTTP API server processing files submitted as jobs,
+// using a bounded queue of jobs processed by a fixed number of workers.
+type Server struct {
+	settings      config.Server
+	inputDirPath  string
+	uploadDirPath string
+	processor     Processor
+	logger        Logger
+	queue         chan *job
+	jobs          map[string]*job
+	jobsMutex     sync.RWMutex
+	// ctx is the parent context of all jobs,
+	// canceled when the server stops.
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+}
+
+// New creates a new server. Server-local file paths submitted must
+// be in the input directory given, and uploaded files are stored in
+// the upload directory given. The server settings given must have
+// their defaults set and be valid.
+func New(settings config.Server, inputDirPath, uploadDirPath string,
+	processor Processor, logger Logger) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		settings:      settings,
+		inputDirPath:  inputDirPath,
+		uploadDirPath: uploadDirPath,
+		processor:     processor,
+		logger:        logger,
+		queue:         make(chan *job, *settings.QueueSize),
+		jobs:          make(map[string]*job),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Run runs the HTTP server and the job workers until the context
+// is canceled, at which point running jobs are canceled.
+func (s *Server) Run(ctx context.Context) (err error) {
+	defer s.cancel()
+
+	waitWorkers := s.startWorkers()
+
+	const readHeaderTimeout = 5 * time.Second
+	httpServer := &http.Server{
+		Addr:              s.settings.Address,
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	s.logger.Info("listening on " + s.settings.Address)
+
+	select {
+	case err = <-errCh:
+		s.cancel()
+		waitWorkers()
+		return fmt.Errorf("listening: %w", err)
+	case <-ctx.Done():
+	}
+
+	const shutdownTimeout = 3 * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = httpServer.Shutdown(shutdownCtx)
+	s.cancel()
+	waitWorkers()
+	<-errCh
+	if err != nil {
+		return fmt.Errorf("shutting down: %w", err)
+	}
+	return ctx.Err()
+}
+
+// startWorkers starts the job workers and the expired jobs cleaner,
+// which stop once the server context is canceled. The function
+// returned waits for all of them to stop.
+func (s *Server) startWorkers() (wait func()) {
+	var waitGroup sync.WaitGroup
+	for i := uint(0); i < *s.settings.Workers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			s.work()
+		}()
+	}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		s.cleanExpiredJobs()
+	}()
+	return waitGroup.Wait
+}
+
+func (s *Server) work() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case job := <-s.queue:
+			if !job.start() { // canceled while queued
+				s.removeUpload(job, "")
+				continue
+			}
+			result, err := s.processor.ProcessFile(job.ctx, job.inputPath)
+			s.removeUpload(job, result.OutputPath)
+			job.finish(result, err)
+			if err != nil {
+				s.logger.Error(fmt.Sprintf("job %s: %s", job.id, err))
+			}
+		}
+	}
+}
+
+// removeUpload removes the upload directory of the job given, since
+// its uploaded input file is no longer needed once processed. The
+// directory is kept if it contains the output path given, as in
+// in-place mode, and is then removed once the job expires.
+func (s *Server) removeUpload(job *job, outputPath string) {
+	if job.uploadDirPath == "" || isInDir(outputPath, job.uploadDirPath) {
+		return
+	}
+	err := os.RemoveAll(job.uploadDirPath)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("job %s: removing upload directory: %s", job.id, err))
+	}
+}
+
+// cleanExpiredJobs removes expired jobs periodically,
+// until the server context is canceled.
+func (s *Server) cleanExpiredJobs() {
+	const maxPeriod = time.Minute
+	ticker := time.NewTicker(min(s.settings.JobRetention, maxPeriod))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.removeExpiredJobs(now)
+		}
+	}
+}
+
+// removeExpiredJobs forgets jobs finished for longer than the job
+// retention at the time given, and removes the upload directory and
+// the output file of jobs processing an uploaded file. Output files
+// of server-local input files are kept, as for a normal run.
+func (s *Server) removeExpiredJobs(now time.Time) {
+	var expiredJobs []*job
+	s.jobsMutex.Lock()
+	for id, job := range s.jobs {
+		if job.expired(now, s.settings.JobRetention) {
+			expiredJobs = append(expiredJobs, job)
+			delete(s.jobs, id)
+		}
+	}
+	s.jobsMutex.Unlock()
+
+	for _, job := range expiredJobs {
+		if job.uploadDirPath == "" {
+			continue
+		}
+
+		err := os.RemoveAll(job.uploadDirPath)
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("job %s: removing upload directory: %s", job.id, err))
+		}
+
+		outputPath := job.resultOutputPath()
+		if outputPath == "" {
+			continue
+		}
+		err = os.Remove(outputPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Error(fmt.Sprintf("job %s: removing output file: %s", job.id, err))
+		}
+		// Remove the job output directory, only if it is empty.
+		_ = os.Remove(filepath.Dir(outputPath))
+	}
+}
+
+func isInDir(filePath, dirPath string) bool {
+	relativePath, err := filepath.Rel(dirPath, filePath)
+	return err == nil && relativePath != ".." &&
+		!strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+}
+
+var ErrQueueFull = errors.New("job queue is full")
+
+// enqueue adds the job given to the queue, and returns an
+// error if the queue is full.
+func (s *Server) enqueue(job *job) (err error) {
+	select {
+	case s.queue <- job:
+	default:
+		return fmt.Errorf("%w: %d jobs queued", ErrQueueFull, *s.settings.QueueSize)
+	}
+
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+	s.jobs[job.id] = job
+	return nil
+}
+
+func (s *Server) getJob(id string) (job *job, ok bool) {
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+	job, ok = s.jobs[id]
+	return job, ok
+}
+
+var ErrNotFound = errors.New("not found")
+
+// ServeHTTP routes the request given to its handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(urlPath, "/")
+	switch {
+	case urlPath == "health":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: s.handleHealth,
+		})
+	case urlPath == "jobs":
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodPost: s.handleSubmit,
+		})
+	case len(parts) == 2 && parts[0] == "jobs": //nolint:gomnd
+		id := parts[1]
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, _ *http.Request) {
+				s.handleStatus(w, id)
+			},
+			http.MethodDelete: func(w http.ResponseWriter, _ *http.Request) {
+				s.handleCancel(w, id)
+			},
+		})
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "result": //nolint:gomnd
+		id := parts[1]
+		s.route(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+				s.handleResult(w, r, id)
+			},
+		})
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", ErrNotFound, r.URL.Path))
+	}
+}
+
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request,
+	methodToHandler map[string]http.HandlerFunc) {
+	handler, ok := methodToHandler[r.Method]
+	if !ok {
+		writeError(w, http.StatusMethodNotAllowed,
+			fmt.Errorf("%w: %s", ErrMethodNotAllowed, r.Method))
+		return
+	}
+	handler(w, r)
+}
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopLogger struct{}
+
+func (noopLogger) Info(string)  {}
+func (noopLogger) Error(string) {}
+
+func newTestServer(t *testing.T, processor Processor, queueSize uint,
+	startWorkers bool) (server *Server, httpServer *httptest.Server) {
+	t.Helper()
+
+	settings := config.Server{
+		QueueSize:    &queueSize,
+		Workers:      ptrTo(uint(1)),
+		MaxUploadMB:  ptrTo(uint(1)),
+		JobRetention: time.Hour,
+	}
+	server = New(settings, t.TempDir(), t.TempDir(), processor, noopLogger{})
+	httpServer = httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	waitWorkers := func() {}
+	if startWorkers {
+		waitWorkers = server.startWorkers()
+	}
+	t.Cleanup(func() {
+		server.cancel()
+		waitWorkers()
+	})
+
+	return server, httpServer
+}
+
+func ptrTo[T any](value T) *T { return &value }
+
+func submitUpload(t *testing.T, url, fileName string, content []byte) (
+	response *http.Response) {
+	t.Helper()
+
+	body := bytes.NewBuffer(nil)
+	multipartWriter := multipart.NewWriter(body)
+	fileWriter, err := multipartWriter.CreateFormFile("file", fileName)
+	require.NoError(t, err)
+	_, err = fileWriter.Write(content)
+	require.NoError(t, err)
+	err = multipartWriter.Close()
+	require.NoError(t, err)
+
+	response, err = http.Post(url+"/jobs", //nolint:noctx
+		multipartWriter.FormDataContentType(), body)
+	require.NoError(t, err)
+	return response
+}
+
+func submitPath(t *testing.T, url, path string) (response *http.Response) {
+	t.Helper()
+	body := strings.NewReader(`{"path":"` + path + `"}`)
+	response, err := http.Post(url+"/jobs", "application/json", body) //nolint:noctx
+	require.NoError(t, err)
+	return response
+}
+
+func decodeJob(t *testing.T, response *http.Response) (job jobJSON) {
+	t.Helper()
+	defer response.Body.Close()
+	err := json.NewDecoder(response.Body).Decode(&job)
+	require.NoError(t, err)
+	return job
+}
+
+func do(t *testing.T, method, url string) (response *http.Response) {
+	t.Helper()
+	request, err := http.NewRequestWithContext(context.Background(), method, url, nil)
+	require.NoError(t, err)
+	response, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	return response
+}
+
+// waitForStatus polls the job status until it is the status given.
+func waitForStatus(t *testing.T, url, id string, status Status) (job jobJSON) {
+	t.Helper()
+	const timeout = time.Second
+	deadline := time.Now().Add(timeout)
+	for {
+		job = decodeJob(t, do(t, http.MethodGet, url+"/jobs/"+id))
+		if job.Status == status {
+			return job
+		}
+		require.True(t, time.Now().Before(deadline),
+			"job status is %s instead of %s", job.Status, status)
+		const pollPeriod = 10 * time.Millisecond
+		time.Sleep(pollPeriod)
+	}
+}
+
+func Test_Server_health(t *testing.T) {
+	t.Parallel()
+
+	_, httpServer := newTestServer(t, nil, 1, false)
+
+	response := do(t, http.MethodGet, httpServer.URL+"/health")
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	response = do(t, http.MethodPost, httpServer.URL+"/health")
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func Test_Server_upload(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+
+	outputPath := filepath.Join(t.TempDir(), "photo.jpg")
+	processor := NewMockProcessor(ctrl)
+	processor.EXPECT().ProcessFile(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, filePath string) (report.File, error) {
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return report.File{}, err
+			}
+			const perms os.FileMode = 0600
+			err = os.WriteFile(outputPath, data[:4], perms)
+			return report.File{
+				InputPath:  filePath,
+				OutputPath: outputPath,
+				Status:     report.StatusConverted,
+			}, err
+		})
+	_, httpServer := newTestServer(t, processor, 1, true)
+
+	response := submitUpload(t, httpServer.URL, "photo.png", []byte("png data"))
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+	job := decodeJob(t, response)
+	assert.Equal(t, "photo.png", filepath.Base(job.InputPath))
+
+	job = waitForStatus(t, httpServer.URL, job.ID, StatusDone)
+	require.NotNil(t, job.Result)
+	assert.Equal(t, report.StatusConverted, job.Result.Status)
+
+	response = do(t, http.MethodGet, httpServer.URL+"/jobs/"+job.ID+"/result")
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	data, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "png ", string(data))
+	assert.Equal(t, `attachment; filename=photo.jpg`,
+		response.Header.Get("Content-Disposition"))
+}
+
+func Test_Server_localPath(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newTestServer(t, nil, 1, false)
+	const perms os.FileMode = 0600
+	err := os.WriteFile(filepath.Join(server.inputDirPath, "a.png"), nil, perms)
+	require.NoError(t, err)
+	outsidePath := filepath.Join(t.TempDir(), "secret.txt")
+	err = os.WriteFile(outsidePath, []byte("secret"), perms)
+	require.NoError(t, err)
+	err = os.Symlink(outsidePath, filepath.Join(server.inputDirPath, "link.txt"))
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		path   string
+		status int
+	}{
+		"outside input directory": {
+			path:   "../a.png",
+			status: http.StatusBadRequest,
+		},
+		"symbolic link to outside input directory": {
+			path:   "link.txt",
+			status: http.StatusBadRequest,
+		},
+		"not existing": {
+			path:   "b.png",
+			status: http.StatusBadRequest,
+		},
+		"relative to input directory": {
+			path:   "a.png",
+			status: http.StatusAccepted,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			response := submitPath(t, httpServer.URL, testCase.path)
+			defer response.Body.Close()
+			assert.Equal(t, testCase.status, response.StatusCode)
+		})
+	}
+}
+
+func Test_Server_queueFull(t *testing.T) {
+	t.Parallel()
+
+	_, httpServer := newTestServer(t, nil, 1, false)
+
+	response := submitUpload(t, httpServer.URL, "a.png", []byte("a"))
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	response = submitUpload(t, httpServer.URL, "b.png", []byte("b"))
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+}
+
+func Test_Server_cancelQueued(t *testing.T) {
+	t.Parallel()
+
+	_, httpServer := newTestServer(t, nil, 1, false)
+
+	job := decodeJob(t, submitUpload(t, httpServer.URL, "a.png", []byte("a")))
+
+	response := do(t, http.MethodGet, httpServer.URL+"/jobs/"+job.ID+"/result")
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response = do(t, http.MethodDelete, httpServer.URL+"/jobs/"+job.ID)
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+	job = decodeJob(t, response)
+	assert.Equal(t, StatusCanceled, job.Status)
+
+	response = do(t, http.MethodDelete, httpServer.URL+"/jobs/"+job.ID)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+}
+
+func Test_Server_cancelRunning(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+
+	started := make(chan struct{})
+	processor := NewMockProcessor(ctrl)
+	processor.EXPECT().ProcessFile(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ string) (report.File, error) {
+			close(started)
+			<-ctx.Done()
+			return report.File{Status: report.StatusFailed}, ctx.Err()
+		})
+	_, httpServer := newTestServer(t, processor, 1, true)
+
+	job := decodeJob(t, submitUpload(t, httpServer.URL, "a.mp4", []byte("a")))
+	<-started
+
+	response := do(t, http.MethodDelete, httpServer.URL+"/jobs/"+job.ID)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+
+	job = waitForStatus(t, httpServer.URL, job.ID, StatusCanceled)
+	assert.Equal(t, "context canceled", job.Error)
+}
+
+func Test_Server_jobRetention(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+
+	outputPath := filepath.Join(t.TempDir(), "job", "photo.jpg")
+	processor := NewMockProcessor(ctrl)
+	processor.EXPECT().ProcessFile(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, filePath string) (report.File, error) {
+			const dirPerms, filePerms os.FileMode = 0700, 0600
+			err := os.Mkdir(filepath.Dir(outputPath), dirPerms)
+			if err != nil {
+				return report.File{}, err
+			}
+			err = os.WriteFile(outputPath, []byte("jpg"), filePerms)
+			return report.File{
+				InputPath:  filePath,
+				OutputPath: outputPath,
+				Status:     report.StatusConverted,
+			}, err
+		})
+	server, httpServer := newTestServer(t, processor, 1, true)
+
+	job := decodeJob(t, submitUpload(t, httpServer.URL, "photo.png", []byte("png")))
+	job = waitForStatus(t, httpServer.URL, job.ID, StatusDone)
+
+	// The uploaded file is removed once the job is finished.
+	_, err := os.Stat(filepath.Dir(job.InputPath))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	server.removeExpiredJobs(time.Now())
+	response := do(t, http.MethodGet, httpServer.URL+"/jobs/"+job.ID)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	server.removeExpiredJobs(time.Now().Add(2 * time.Hour))
+	response = do(t, http.MethodGet, httpServer.URL+"/jobs/"+job.ID)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	_, err = os.Stat(filepath.Dir(outputPath))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
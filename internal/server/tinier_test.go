@@ -0,0 +1,90 @@
+//go:build !windows
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/pkg/tinier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeFFMPEG = `#!/bin/sh
+if [ "$1" = "-version" ]; then
+	echo "ffmpeg version 6.0.1 Copyright (c) fake"
+	exit 0
+fi
+case "$2" in
+	-encoders)
+		echo " V....D libsvtav1            SVT-AV1(Scalable Video Technology for AV1) encoder (codec av1)"
+		echo " V....D mjpeg                MJPEG (Motion JPEG)"
+		echo " V....D png                  PNG (Portable Network Graphics) image"
+		echo " A....D libopus              libopus Opus (codec opus)"
+		exit 0 ;;
+	-muxers)
+		echo "  E image2          image2 sequence"
+		echo "  E mp4             MP4 (MPEG-4 Part 14)"
+		echo "  E opus            Ogg Opus"
+		exit 0 ;;
+	-filters) exit 0 ;;
+esac
+for arg; do
+	case "$arg" in
+		null) exit 0 ;; # decoding to verify the output
+		*.opus) output="$arg" ;;
+	esac
+done
+printf tiny > "$output"
+`
+
+const fakeFFProbe = `#!/bin/sh
+echo '{"streams":[{"index":0,"codec_type":"audio","codec_name":"mp3",` +
+	`"bit_rate":"320000"}],"format":{"format_name":"mp3"}}'
+`
+
+func Test_Server_tinierProcessor(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	const perms os.FileMode = 0700
+	ffmpegPath := filepath.Join(rootDir, "ffmpeg")
+	err := os.WriteFile(ffmpegPath, []byte(fakeFFMPEG), perms)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(rootDir, "ffprobe"), []byte(fakeFFProbe), perms)
+	require.NoError(t, err)
+
+	settings := config.Settings{
+		InputDirPath:  rootDir,
+		OutputDirPath: filepath.Join(rootDir, "output"),
+		FfmpegPath:    &ffmpegPath,
+	}
+	processor, err := tinier.New(context.Background(), settings, nil, nil, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = processor.Close()
+	})
+
+	_, httpServer := newTestServer(t, processor, 1, true)
+
+	response := submitUpload(t, httpServer.URL, "song.mp3", []byte("mp3 audio data"))
+	require.Equal(t, http.StatusAccepted, response.StatusCode)
+	job := decodeJob(t, response)
+
+	job = waitForStatus(t, httpServer.URL, job.ID, StatusDone)
+	require.NotNil(t, job.Result)
+	assert.Equal(t, tinier.StatusConverted, job.Result.Status)
+
+	response = do(t, http.MethodGet, httpServer.URL+"/jobs/"+job.ID+"/result")
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	data, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "tiny", string(data))
+}
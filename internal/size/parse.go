@@ -0,0 +1,64 @@
+package size
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSizeMalformed    = errors.New("size is malformed")
+	ErrBitRateMalformed = errors.New("bit rate is malformed")
+)
+
+// Parse parses a human readable size such as `25MB`, `1.5GB` or `500K`
+// into a number of bytes, where units are powers of 1024 as for the
+// sizes returned by BytesToHuman. A size without unit is in bytes.
+func Parse(s string) (bytes int64, err error) {
+	number, multiplier, ok := splitUnit(strings.TrimSuffix(strings.ToUpper(s), "B"),
+		1024) //nolint:gomnd
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrSizeMalformed, s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrSizeMalformed, s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// ParseBitRate parses a bit rate such as `2M`, `500k` or `64000` into
+// a number of bits per second, where units are powers of 1000 as for
+// ffmpeg bit rate options.
+func ParseBitRate(s string) (bitsPerSecond uint64, err error) {
+	number, multiplier, ok := splitUnit(strings.ToUpper(s), 1000) //nolint:gomnd
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrBitRateMalformed, s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrBitRateMalformed, s)
+	}
+	return uint64(value * float64(multiplier)), nil
+}
+
+// splitUnit splits the upper cased string given into its number and
+// the multiplier of its unit suffix, which can be K, M or G.
+func splitUnit(s string, base int64) (number string, multiplier int64, ok bool) {
+	if s == "" {
+		return "", 0, false
+	}
+
+	multiplier = 1
+	units := "KMG"
+	if i := strings.IndexByte(units, s[len(s)-1]); i >= 0 {
+		s = s[:len(s)-1]
+		for j := 0; j <= i; j++ {
+			multiplier *= base
+		}
+	}
+	return s, multiplier, s != ""
+}
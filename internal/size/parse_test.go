@@ -0,0 +1,92 @@
+package size
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Parse(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		s          string
+		bytes      int64
+		errWrapped error
+	}{
+		"bytes": {
+			s:     "100",
+			bytes: 100,
+		},
+		"megabytes": {
+			s:     "25MB",
+			bytes: 25 * 1024 * 1024,
+		},
+		"fractional gigabytes short": {
+			s:     "1.5g",
+			bytes: 1536 * 1024 * 1024,
+		},
+		"empty": {
+			errWrapped: ErrSizeMalformed,
+		},
+		"unit only": {
+			s:          "MB",
+			errWrapped: ErrSizeMalformed,
+		},
+		"negative": {
+			s:          "-1MB",
+			errWrapped: ErrSizeMalformed,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bytes, err := Parse(testCase.s)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			assert.Equal(t, testCase.bytes, bytes)
+		})
+	}
+}
+
+func Test_ParseBitRate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		s             string
+		bitsPerSecond uint64
+		errWrapped    error
+	}{
+		"bits per second": {
+			s:             "64000",
+			bitsPerSecond: 64000,
+		},
+		"kilobits": {
+			s:             "500k",
+			bitsPerSecond: 500000,
+		},
+		"fractional megabits": {
+			s:             "2.5M",
+			bitsPerSecond: 2500000,
+		},
+		"malformed": {
+			s:          "fast",
+			errWrapped: ErrBitRateMalformed,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bitsPerSecond, err := ParseBitRate(testCase.s)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			assert.Equal(t, testCase.bitsPerSecond, bitsPerSecond)
+		})
+	}
+}
@@ -4,16 +4,20 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/qdm12/tinier/internal/size"
 )
 
+// Stats holds statistics of a run and is safe for concurrent use
+// through its methods.
 type Stats struct {
 	Failures   int
 	InputSize  int64
 	OutputSize int64
 	Start      time.Time
+	mutex      sync.Mutex
 }
 
 func New() *Stats {
@@ -22,7 +26,33 @@ func New() *Stats {
 	}
 }
 
+// AddFailure increments the failures count by one.
+func (s *Stats) AddFailure() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Failures++
+}
+
+// AddSizes adds the input and output sizes given to the totals.
+func (s *Stats) AddSizes(inputSize, outputSize int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.InputSize += inputSize
+	s.OutputSize += outputSize
+}
+
+// Totals returns the failures count and the total input
+// and output sizes.
+func (s *Stats) Totals() (failures int, inputSize, outputSize int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Failures, s.InputSize, s.OutputSize
+}
+
 func (s *Stats) Finish(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	if s.InputSize == 0 {
 		return
 	}
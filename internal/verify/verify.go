@@ -0,0 +1,101 @@
+// Package verify checks the media information of a converted file
+// matches the media information of its input file, such that a
+// truncated or otherwise broken output is not accepted.
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/qdm12/tinier/internal/models"
+)
+
+var (
+	ErrStreamMissing    = errors.New("stream missing")
+	ErrStreamsExtra     = errors.New("more streams than input")
+	ErrDurationMismatch = errors.New("duration mismatch")
+)
+
+// Compare returns an error if the output media information given does
+// not match the input media information given, for the media type given.
+// The output must have at least one stream of each stream type relevant
+// to the media type found in the input, and no more than the input has,
+// except for images where the alpha channel may be in an extra stream,
+// as for AVIF images.
+// For audio and video files, the output duration must be within the
+// greater of one second or 1% of the input duration, if both are known.
+func Compare(input, output ffprobe.Info, mediaType models.MediaType) (err error) {
+	var streamTypes []ffprobe.StreamType
+	switch mediaType {
+	case models.MediaTypeImage:
+		streamTypes = []ffprobe.StreamType{ffprobe.StreamTypeVideo}
+	case models.MediaTypeAudio:
+		streamTypes = []ffprobe.StreamType{ffprobe.StreamTypeAudio}
+	case models.MediaTypeVideo:
+		streamTypes = []ffprobe.StreamType{ffprobe.StreamTypeVideo, ffprobe.StreamTypeAudio}
+	case models.MediaTypeOther:
+		return nil
+	default:
+		panic(fmt.Sprintf("media type %q not implemented", mediaType))
+	}
+
+	allowExtra := mediaType == models.MediaTypeImage
+	for _, streamType := range streamTypes {
+		err = compareStreamsCount(input, output, streamType, allowExtra)
+		if err != nil {
+			return err
+		}
+	}
+
+	if mediaType == models.MediaTypeImage {
+		return nil
+	}
+	return compareDuration(input.Format.Duration, output.Format.Duration)
+}
+
+func compareStreamsCount(input, output ffprobe.Info, streamType ffprobe.StreamType,
+	allowExtra bool) (err error) {
+	inputCount := countStreams(input, streamType)
+	outputCount := countStreams(output, streamType)
+	switch {
+	case inputCount > 0 && outputCount == 0:
+		return fmt.Errorf("%w: no %s stream", ErrStreamMissing, streamType)
+	case outputCount > inputCount && !allowExtra:
+		return fmt.Errorf("%w: %d %s streams instead of %d",
+			ErrStreamsExtra, outputCount, streamType, inputCount)
+	default:
+		return nil
+	}
+}
+
+func countStreams(info ffprobe.Info, streamType ffprobe.StreamType) (count int) {
+	for _, stream := range info.Streams {
+		if stream.Type == streamType {
+			count++
+		}
+	}
+	return count
+}
+
+func compareDuration(input, output time.Duration) (err error) {
+	if input == 0 || output == 0 { // unknown
+		return nil
+	}
+
+	tolerance := input / 100 //nolint:gomnd
+	if tolerance < time.Second {
+		tolerance = time.Second
+	}
+
+	difference := output - input
+	if difference < 0 {
+		difference = -difference
+	}
+	if difference > tolerance {
+		return fmt.Errorf("%w: output lasts %s instead of %s",
+			ErrDurationMismatch, output, input)
+	}
+	return nil
+}
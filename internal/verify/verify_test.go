@@ -0,0 +1,121 @@
+package verify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Compare(t *testing.T) {
+	t.Parallel()
+
+	videoStream := ffprobe.Stream{Type: ffprobe.StreamTypeVideo}
+	audioStream := ffprobe.Stream{Type: ffprobe.StreamTypeAudio}
+
+	testCases := map[string]struct {
+		input      ffprobe.Info
+		output     ffprobe.Info
+		mediaType  models.MediaType
+		errWrapped error
+		errMessage string
+	}{
+		"image matching": {
+			input:     ffprobe.Info{Streams: []ffprobe.Stream{videoStream}},
+			output:    ffprobe.Info{Streams: []ffprobe.Stream{videoStream}},
+			mediaType: models.MediaTypeImage,
+		},
+		"image without stream": {
+			input:      ffprobe.Info{Streams: []ffprobe.Stream{videoStream}},
+			mediaType:  models.MediaTypeImage,
+			errWrapped: ErrStreamMissing,
+			errMessage: "stream missing: no video stream",
+		},
+		"image with alpha plane stream": {
+			input:     ffprobe.Info{Streams: []ffprobe.Stream{videoStream}},
+			output:    ffprobe.Info{Streams: []ffprobe.Stream{videoStream, videoStream}},
+			mediaType: models.MediaTypeImage,
+		},
+		"audio with cover art dropped": {
+			input: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Minute},
+				Streams: []ffprobe.Stream{audioStream, videoStream},
+			},
+			output: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Minute},
+				Streams: []ffprobe.Stream{audioStream},
+			},
+			mediaType: models.MediaTypeAudio,
+		},
+		"video with one of two audio streams": {
+			input: ffprobe.Info{
+				Streams: []ffprobe.Stream{videoStream, audioStream, audioStream},
+			},
+			output: ffprobe.Info{
+				Streams: []ffprobe.Stream{videoStream, audioStream},
+			},
+			mediaType: models.MediaTypeVideo,
+		},
+		"video with extra audio stream": {
+			input: ffprobe.Info{
+				Streams: []ffprobe.Stream{videoStream},
+			},
+			output: ffprobe.Info{
+				Streams: []ffprobe.Stream{videoStream, audioStream},
+			},
+			mediaType:  models.MediaTypeVideo,
+			errWrapped: ErrStreamsExtra,
+			errMessage: "more streams than input: 1 audio streams instead of 0",
+		},
+		"video duration within tolerance": {
+			input: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Hour},
+				Streams: []ffprobe.Stream{videoStream},
+			},
+			output: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Hour - 30*time.Second},
+				Streams: []ffprobe.Stream{videoStream},
+			},
+			mediaType: models.MediaTypeVideo,
+		},
+		"video truncated": {
+			input: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Minute},
+				Streams: []ffprobe.Stream{videoStream},
+			},
+			output: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: 30 * time.Second},
+				Streams: []ffprobe.Stream{videoStream},
+			},
+			mediaType:  models.MediaTypeVideo,
+			errWrapped: ErrDurationMismatch,
+			errMessage: "duration mismatch: output lasts 30s instead of 1m0s",
+		},
+		"unknown output duration": {
+			input: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Minute},
+				Streams: []ffprobe.Stream{audioStream},
+			},
+			output: ffprobe.Info{
+				Streams: []ffprobe.Stream{audioStream},
+			},
+			mediaType: models.MediaTypeAudio,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Compare(testCase.input, testCase.output, testCase.mediaType)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package watch
+
+type Logger interface {
+	Debug(msg string)
+	Warn(msg string)
+}
@@ -0,0 +1,235 @@
+// Package watch watches a directory tree for new or modified files.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type Watcher struct {
+	rootDir         string
+	stabilityPeriod time.Duration
+	excludedDirs    []string
+	logger          Logger
+}
+
+// New creates a watcher for the root directory given and all its
+// subdirectories, except for the excluded directories given.
+// Files are considered ready once their size did not change for
+// the stability period given.
+func New(rootDir string, stabilityPeriod time.Duration,
+	logger Logger, excludedDirs ...string) *Watcher {
+	cleanedExcludedDirs := make([]string, len(excludedDirs))
+	for i, excludedDir := range excludedDirs {
+		cleanedExcludedDirs[i] = filepath.Clean(excludedDir)
+	}
+
+	return &Watcher{
+		rootDir:         filepath.Clean(rootDir),
+		stabilityPeriod: stabilityPeriod,
+		excludedDirs:    cleanedExcludedDirs,
+		logger:          logger,
+	}
+}
+
+type pendingFile struct {
+	size int64
+	// stableSince is the last time the file size changed.
+	stableSince time.Time
+}
+
+// Run watches for new or modified files until the context is canceled,
+// and calls onReady with file paths once their size is stable.
+// Calls to onReady are sequential and do not block the watching, such
+// that files ready while onReady is running are batched together for
+// the next onReady call.
+func (w *Watcher) Run(ctx context.Context,
+	onReady func(filePaths []string)) (err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating watcher: %w", err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	pending := make(map[string]pendingFile)
+	err = w.addRecursive(watcher, w.rootDir, pending, false)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", w.rootDir, err)
+	}
+
+	readyCh := make(chan []string)
+	processingDone := make(chan struct{})
+	go func() {
+		defer close(processingDone)
+		for filePaths := range readyCh {
+			onReady(filePaths)
+		}
+	}()
+	defer func() {
+		close(readyCh)
+		<-processingDone
+	}()
+
+	const minTickPeriod = 100 * time.Millisecond
+	tickPeriod := w.stabilityPeriod / 2 //nolint:gomnd
+	if tickPeriod < minTickPeriod {
+		tickPeriod = minTickPeriod
+	}
+	ticker := time.NewTicker(tickPeriod)
+	defer ticker.Stop()
+
+	// ready is the set of ready file paths not yet passed to onReady,
+	// such that a file ready again while onReady is running is only
+	// passed once, and not converted twice concurrently.
+	ready := make(map[string]struct{})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.handleEvent(watcher, event, pending)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Warn("watching: " + err.Error())
+		case <-ticker.C:
+			for _, path := range w.collectReady(pending) {
+				ready[path] = struct{}{}
+			}
+			if len(ready) == 0 {
+				continue
+			}
+			select {
+			case readyCh <- sortedPaths(ready):
+				ready = make(map[string]struct{})
+			default: // still processing previous files
+			}
+		}
+	}
+}
+
+func (w *Watcher) handleEvent(watcher *fsnotify.Watcher,
+	event fsnotify.Event, pending map[string]pendingFile) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+
+	fileInfo, err := os.Stat(event.Name)
+	if err != nil {
+		// file may have been removed or renamed since
+		return
+	}
+
+	if fileInfo.IsDir() {
+		if !event.Has(fsnotify.Create) {
+			return
+		}
+		// Files may have been created in the new directory
+		// before it is watched, so add them as pending.
+		err = w.addRecursive(watcher, event.Name, pending, true)
+		if err != nil {
+			w.logger.Warn(fmt.Sprintf("watching %s: %s", event.Name, err))
+		}
+		return
+	}
+
+	w.logger.Debug(fmt.Sprintf("%s: %s", event.Op, event.Name))
+	pending[event.Name] = pendingFile{
+		size:        fileInfo.Size(),
+		stableSince: time.Now(),
+	}
+}
+
+// addRecursive watches the directory given and all its subdirectories.
+// If addFiles is true, files found are added to the pending files.
+func (w *Watcher) addRecursive(watcher *fsnotify.Watcher, dirPath string,
+	pending map[string]pendingFile, addFiles bool) (err error) {
+	return filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if !d.IsDir() {
+			if addFiles {
+				pending[path] = pendingFile{size: -1, stableSince: time.Now()}
+			}
+			return nil
+		}
+
+		if w.isExcluded(path) {
+			return filepath.SkipDir
+		}
+
+		err = watcher.Add(path)
+		if err != nil {
+			return fmt.Errorf("adding %s to watcher: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) isExcluded(dirPath string) bool {
+	dirPath = filepath.Clean(dirPath)
+	for _, excludedDir := range w.excludedDirs {
+		if dirPath == excludedDir ||
+			strings.HasPrefix(dirPath, excludedDir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectReady returns the pending file paths which size did not change
+// for the stability period, and removes them from the pending files.
+func (w *Watcher) collectReady(pending map[string]pendingFile) (
+	filePaths []string) {
+	now := time.Now()
+	for path, file := range pending {
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			delete(pending, path)
+			continue
+		}
+
+		if fileInfo.Size() != file.size {
+			pending[path] = pendingFile{
+				size:        fileInfo.Size(),
+				stableSince: now,
+			}
+			continue
+		}
+
+		if now.Sub(file.stableSince) >= w.stabilityPeriod {
+			filePaths = append(filePaths, path)
+			delete(pending, path)
+		}
+	}
+	return filePaths
+}
+
+func sortedPaths(set map[string]struct{}) (paths []string) {
+	paths = make([]string, 0, len(set))
+	for path := range set {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
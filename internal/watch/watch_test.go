@@ -0,0 +1,118 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(string) {}
+func (noopLogger) Warn(string)  {}
+
+func Test_Watcher_Run(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	excludedDir := filepath.Join(rootDir, "output")
+	err := os.Mkdir(excludedDir, 0700)
+	require.NoError(t, err)
+
+	const stabilityPeriod = 50 * time.Millisecond
+	watcher := New(rootDir, stabilityPeriod, noopLogger{}, excludedDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	readyCh := make(chan []string)
+	runErrCh := make(chan error)
+	go func() {
+		runErrCh <- watcher.Run(ctx, func(filePaths []string) {
+			readyCh <- filePaths
+		})
+	}()
+
+	// Wait for the watcher to watch the directories
+	time.Sleep(stabilityPeriod)
+
+	err = os.WriteFile(filepath.Join(excludedDir, "excluded.jpg"), nil, 0600)
+	require.NoError(t, err)
+
+	subDir := filepath.Join(rootDir, "sub")
+	err = os.Mkdir(subDir, 0700)
+	require.NoError(t, err)
+	filePath := filepath.Join(subDir, "file.jpg")
+	err = os.WriteFile(filePath, []byte{1}, 0600)
+	require.NoError(t, err)
+
+	const timeout = time.Second
+	timer := time.NewTimer(timeout)
+	select {
+	case filePaths := <-readyCh:
+		assert.Equal(t, []string{filePath}, filePaths)
+		timer.Stop()
+	case <-timer.C:
+		t.Fatal("timed out waiting for ready file")
+	}
+
+	cancel()
+	err = <-runErrCh
+	assert.NoError(t, err)
+}
+
+func Test_Watcher_Run_readyAgainWhileProcessing(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+
+	const stabilityPeriod = 50 * time.Millisecond
+	watcher := New(rootDir, stabilityPeriod, noopLogger{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	readyCh := make(chan []string)
+	runErrCh := make(chan error)
+	go func() {
+		runErrCh <- watcher.Run(ctx, func(filePaths []string) {
+			readyCh <- filePaths
+		})
+	}()
+
+	// Wait for the watcher to watch the directories
+	time.Sleep(stabilityPeriod)
+
+	// The first file keeps onReady busy until its paths are received.
+	firstPath := filepath.Join(rootDir, "first.jpg")
+	err := os.WriteFile(firstPath, []byte{1}, 0600)
+	require.NoError(t, err)
+	const readyWait = 6 * stabilityPeriod
+	time.Sleep(readyWait)
+
+	// The second file gets ready twice while onReady is busy.
+	secondPath := filepath.Join(rootDir, "second.jpg")
+	err = os.WriteFile(secondPath, []byte{1}, 0600)
+	require.NoError(t, err)
+	time.Sleep(readyWait)
+	err = os.WriteFile(secondPath, []byte{1, 2}, 0600)
+	require.NoError(t, err)
+	time.Sleep(readyWait)
+
+	const timeout = time.Second
+	for _, expectedPaths := range [][]string{{firstPath}, {secondPath}} {
+		timer := time.NewTimer(timeout)
+		select {
+		case filePaths := <-readyCh:
+			assert.Equal(t, expectedPaths, filePaths)
+			timer.Stop()
+		case <-timer.C:
+			t.Fatal("timed out waiting for ready file")
+		}
+	}
+
+	cancel()
+	err = <-runErrCh
+	assert.NoError(t, err)
+}
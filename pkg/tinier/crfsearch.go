@@ -0,0 +1,82 @@
+package tinier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/crfsearch"
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/qdm12/tinier/internal/report"
+)
+
+// sampleDuration is the duration of each sample segment
+// encoded to search the CRF of a video.
+const sampleDuration = 10 * time.Second
+
+// searchCRF searches the highest CRF for which sample segments of the
+// input video, encoded with the video settings and scale given, reach
+// the target quality on average. If no CRF reaches the target quality,
+// the lowest CRF is returned.
+func (p *Processor) searchCRF(ctx context.Context, settings config.Settings,
+	inputPath string, info ffprobe.Info, videoScale string, file *report.File) (
+	crf uint, outcome string, err error) {
+	tempDir, err := os.MkdirTemp("", "tinier-crf-search-")
+	if err != nil {
+		return 0, "", fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+
+	samples := crfsearch.Samples(info.Format.Duration,
+		*settings.Video.TargetSamples, sampleDuration)
+	samplePaths := make([]string, len(samples))
+	for i, sample := range samples {
+		samplePaths[i] = filepath.Join(tempDir, fmt.Sprintf("sample%d.mkv", i))
+		err = p.ffmpeg.ExtractSample(ctx, inputPath, samplePaths[i],
+			sample.Start, sample.Duration)
+		if err != nil {
+			return 0, "", fmt.Errorf("extracting sample: %w", err)
+		}
+	}
+
+	metric := settings.Video.TargetMetric
+	score := func(ctx context.Context, crf uint) (score float64, err error) {
+		var sum float64
+		for i, samplePath := range samplePaths {
+			encodedPath := filepath.Join(tempDir,
+				fmt.Sprintf("sample%d_crf%d%s", i, crf, settings.Video.OutputExtension))
+			err = p.ffmpeg.TinyVideo(ctx, samplePath, encodedPath, videoScale,
+				settings.Video.Preset, settings.Video.Codec, crf, nil)
+			if err != nil {
+				return 0, fmt.Errorf("encoding sample: %w", err)
+			}
+
+			sampleScore, err := p.ffmpeg.Quality(ctx, samplePath, encodedPath, metric)
+			_ = os.Remove(encodedPath)
+			if err != nil {
+				return 0, fmt.Errorf("scoring sample: %w", err)
+			}
+			sum += sampleScore
+		}
+		return sum / float64(len(samplePaths)), nil
+	}
+
+	crf, crfScore, ok, err := crfsearch.Search(ctx, minCRF, maxCRF,
+		*settings.Video.TargetQuality, score)
+	if err != nil {
+		return 0, "", fmt.Errorf("searching CRF: %w", err)
+	}
+
+	file.CRF = &crf
+	outcome = fmt.Sprintf("🎯 CRF %d (%s %.4g", crf, strings.ToUpper(metric), crfScore)
+	if !ok {
+		outcome += " below target"
+	}
+	return crf, outcome + ") ", nil
+}
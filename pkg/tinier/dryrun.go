@@ -0,0 +1,68 @@
+package tinier
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/journal"
+	"github.com/qdm12/tinier/internal/plan"
+)
+
+// DryRun writes to w what would be done with each file of the input
+// directory, without converting or copying any file, and writes it
+// as JSON to the dry run JSON path if it is set. The logger and
+// writer are optional and can be nil.
+func DryRun(settings Settings, logger Logger, w io.Writer) (err error) {
+	rawSettings, err := prepareSettings(&settings)
+	if err != nil {
+		return err
+	}
+
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	if w == nil {
+		w = io.Discard
+	}
+
+	imagePaths, audioPaths, videoPaths, otherPaths, err := readDir(w,
+		settings.InputDirPath, settings)
+	if err != nil {
+		return err
+	}
+
+	journal, err := journal.Read(journalPath(settings))
+	if err != nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+
+	dirResolver := config.NewDirResolver(settings, rawSettings, nil, logger)
+	plan, err := plan.Make(settings, dirResolver, journal,
+		imagePaths, audioPaths, videoPaths, otherPaths)
+	if err != nil {
+		return fmt.Errorf("making plan: %w", err)
+	}
+
+	plan.WriteText(w)
+
+	if *settings.DryRunJSONPath == "" {
+		return nil
+	}
+
+	const perms os.FileMode = 0600
+	file, err := os.OpenFile(*settings.DryRunJSONPath,
+		os.O_TRUNC|os.O_WRONLY|os.O_CREATE, perms)
+	if err != nil {
+		return fmt.Errorf("opening JSON plan file: %w", err)
+	}
+
+	err = plan.WriteJSON(file)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("writing JSON plan: %w", err)
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,696 @@
+package tinier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/efficient"
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/qdm12/tinier/internal/filetime"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/qdm12/tinier/internal/palette"
+	"github.com/qdm12/tinier/internal/path"
+	"github.com/qdm12/tinier/internal/progress"
+	"github.com/qdm12/tinier/internal/report"
+	"github.com/qdm12/tinier/internal/scale"
+	"github.com/qdm12/tinier/internal/size"
+	"github.com/qdm12/tinier/internal/verify"
+)
+
+func (p *Processor) doOther(settings config.Settings, inputPath string, file *report.File) (
+	outcome string, err error) {
+	_, outputPath := path.InputToOutput(inputPath, settings.OutputDirPath, "")
+	file.OutputPath = outputPath
+
+	if !*settings.OverrideOutput {
+		exist, err := path.DoesFileExist(outputPath)
+		if err != nil {
+			return "", err
+		} else if exist {
+			file.Status = report.StatusSkippedExisting
+			return fileAlreadyExists, nil
+		}
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	const dirPerms os.FileMode = 0700
+	err = os.MkdirAll(outputDir, dirPerms)
+	if err != nil {
+		return "", fmt.Errorf("cannot create parent output directory: %w", err)
+	}
+
+	srcFile, err := os.Open(inputPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot open input file: %w", err)
+	}
+
+	const filePerm os.FileMode = 0600
+	dstFile, err := os.OpenFile(outputPath, os.O_TRUNC|os.O_WRONLY|os.O_CREATE, filePerm)
+	if err != nil {
+		_ = srcFile.Close()
+		return "", fmt.Errorf("cannot open output file: %w", err)
+	}
+
+	written, err := io.Copy(dstFile, srcFile)
+	_ = srcFile.Close()
+	if err != nil {
+		_ = dstFile.Close()
+		_ = os.Remove(outputPath) // clean up
+		return "", fmt.Errorf("cannot copy: %w", err)
+	}
+
+	err = dstFile.Close()
+	if err != nil {
+		_ = os.Remove(outputPath) // clean up
+		return "", fmt.Errorf("cannot close output file: %w", err)
+	}
+
+	err = filetime.Copy(outputPath, inputPath)
+	if err != nil {
+		_ = os.Remove(outputPath) // clean up
+		return "", err
+	}
+
+	file.Status = report.StatusCopied
+	file.SetSizes(written, written)
+	return "✔️", nil
+}
+
+func (p *Processor) doImage(ctx context.Context, settings config.Settings,
+	inputPath string, file *report.File) (outcome string, err error) {
+	info, err := p.ffprobe.Probe(ctx, inputPath)
+	if err != nil {
+		return "", fmt.Errorf("probing input file: %w", err)
+	}
+
+	alpha, err := hasAlpha(info, inputPath)
+	if err != nil {
+		return "", err
+	}
+	if alpha {
+		settings.Image = settings.Image.ForAlpha()
+	}
+
+	tempOutputPath, outputPath := outputPaths(settings, inputPath,
+		settings.Image.OutputExtension)
+	file.OutputPath = outputPath
+
+	exist, err := outputExists(settings, inputPath, outputPath)
+	if err != nil {
+		return "", err
+	} else if exist {
+		file.Status = report.StatusSkippedExisting
+		return fileAlreadyExists, nil
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	const dirPerms os.FileMode = 0700
+	err = os.MkdirAll(outputDir, dirPerms)
+	if err != nil {
+		return "", fmt.Errorf("cannot create parent output directory: %w", err)
+	}
+
+	imageScale, resolution, err := fitScale(info,
+		settings.Image.Scale, *settings.Image.Upscale)
+	if err != nil {
+		return "", err
+	}
+
+	usesPalette, err := usePalette(settings.Image, inputPath, info, resolution)
+	if err != nil {
+		return "", err
+	}
+
+	value, step, minValue, imageOptions := imageQuality(settings.Image)
+
+	defer func() {
+		_ = os.Remove(tempOutputPath) // clean up
+	}()
+	qualityOutcome, accepted, err := p.encodeWithQuality(ctx, settings,
+		inputPath, tempOutputPath, value, step, minValue,
+		func(value uint) error {
+			options := imageOptions(value)
+			options.Alpha = alpha
+			options.Palette = usesPalette
+			return p.ffmpeg.TinyImage(ctx, inputPath, tempOutputPath,
+				imageScale, options)
+		}, file)
+	if err != nil {
+		return "", err
+	} else if !accepted {
+		outcome, err = p.reject(settings, inputPath, tempOutputPath, outputPath, file)
+		return resolutionString(resolution) + qualityOutcome + outcome, err
+	}
+
+	outcome, err = p.finish(ctx, settings, inputPath, info,
+		tempOutputPath, outputPath, file)
+	outcome = resolutionString(resolution) + qualityOutcome + outcome
+	if err != nil {
+		return outcome, err
+	}
+
+	file.Status = report.StatusConverted
+	return outcome, nil
+}
+
+func (p *Processor) doAudio(ctx context.Context, settings config.Settings,
+	inputPath string, file *report.File) (outcome string, err error) {
+	info, err := p.ffprobe.Probe(ctx, inputPath)
+	if err != nil {
+		return "", fmt.Errorf("probing input file: %w", err)
+	}
+
+	if efficient.Audio(info, settings.Audio.Codec, *settings.Audio.TinyKbps) {
+		return p.copyAlreadyTiny(settings, inputPath, file)
+	}
+
+	outputTempPath, outputPath := outputPaths(settings, inputPath,
+		settings.Audio.OutputExtension)
+	file.OutputPath = outputPath
+
+	exist, err := outputExists(settings, inputPath, outputPath)
+	if err != nil {
+		return "", err
+	} else if exist {
+		file.Status = report.StatusSkippedExisting
+		return fileAlreadyExists, nil
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	const dirPerms os.FileMode = 0700
+	err = os.MkdirAll(outputDir, dirPerms)
+	if err != nil {
+		return "", fmt.Errorf("cannot create parent output directory: %w", err)
+	}
+
+	defer func() {
+		_ = os.Remove(outputTempPath) // clean up
+	}()
+	err = p.ffmpeg.TinyAudio(ctx, inputPath, outputTempPath,
+		settings.Audio.Codec, *settings.Audio.QScale, *settings.Audio.BitRate)
+	if err != nil {
+		return "", err
+	}
+
+	outcome, err = p.finish(ctx, settings, inputPath, info,
+		outputTempPath, outputPath, file)
+	if err != nil {
+		return outcome, err
+	}
+
+	file.Status = report.StatusConverted
+	return outcome, nil
+}
+
+func (p *Processor) doVideo(ctx context.Context, settings config.Settings,
+	inputPath string, file *report.File, line string, showProgress bool) (
+	outcome string, err error) {
+	info, err := p.ffprobe.Probe(ctx, inputPath)
+	if err != nil {
+		return "", fmt.Errorf("probing input file: %w", err)
+	}
+
+	videoScale, resolution, err := fitScale(info,
+		settings.Video.Scale, *settings.Video.Upscale)
+	if err != nil {
+		return "", err
+	}
+
+	// A video already tiny can still exceed the target size,
+	// or be larger than the scale resolution.
+	if !settings.Video.TargetSizeMode() &&
+		resolution == sourceResolution(info) &&
+		efficient.Video(info, settings.Video.Codec, *settings.Video.TinyBitsPerPixel) {
+		return p.copyAlreadyTiny(settings, inputPath, file)
+	}
+
+	tempOutputPath, outputPath := outputPaths(settings, inputPath,
+		settings.Video.OutputExtension)
+	file.OutputPath = outputPath
+
+	exist, err := outputExists(settings, inputPath, outputPath)
+	if err != nil {
+		return "", err
+	} else if exist {
+		file.Status = report.StatusSkippedExisting
+		return fileAlreadyExists, nil
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	const dirPerms os.FileMode = 0700
+	err = os.MkdirAll(outputDir, dirPerms)
+	if err != nil {
+		return "", fmt.Errorf("cannot create parent output directory: %w", err)
+	}
+
+	crf := *settings.Video.Crf
+	var crfOutcome string
+	var bitRate uint64
+	switch {
+	case settings.Video.CRFSearch():
+		crf, crfOutcome, err = p.searchCRF(ctx, settings, inputPath, info, videoScale, file)
+		if err != nil {
+			return "", err
+		}
+	case settings.Video.TargetSizeMode():
+		bitRate, err = targetVideoBitRate(settings.Video, info)
+		if err != nil {
+			return "", fmt.Errorf("computing video bit rate: %w", err)
+		}
+		file.VideoBitRate = bitRate
+		crfOutcome = bitRateString(bitRate)
+	}
+
+	onProgress, clearProgress := p.newProgress(line, info.Format.Duration,
+		showProgress, inputPath)
+	defer clearProgress()
+
+	defer func() {
+		_ = os.Remove(tempOutputPath) // clean up
+	}()
+	value, step, minValue := crf, uint(crfStep), uint(minCRF)
+	encode := func(crf uint) error {
+		return p.ffmpeg.TinyVideo(ctx, inputPath, tempOutputPath,
+			videoScale, settings.Video.Preset, settings.Video.Codec,
+			crf, onProgress)
+	}
+	if settings.Video.TargetSizeMode() {
+		// The bit rate cannot be raised without exceeding the target,
+		// so the quality check can only reject the output.
+		value, step, minValue = 0, 0, 0
+		encode = func(uint) error {
+			return p.encodeTargetSize(ctx, settings, inputPath, tempOutputPath,
+				videoScale, bitRate, onProgress)
+		}
+	}
+	qualityOutcome, accepted, err := p.encodeWithQuality(ctx, settings,
+		inputPath, tempOutputPath, value, step, minValue, encode, file)
+	if err != nil {
+		return "", err
+	} else if !accepted {
+		outcome, err = p.reject(settings, inputPath, tempOutputPath, outputPath, file)
+		return resolutionString(resolution) + crfOutcome + qualityOutcome + outcome, err
+	}
+
+	outcome, err = p.finish(ctx, settings, inputPath, info,
+		tempOutputPath, outputPath, file)
+	outcome = resolutionString(resolution) + crfOutcome + qualityOutcome + outcome
+	if err != nil {
+		return outcome, err
+	}
+
+	err = checkTargetSize(settings.Video, file.OutputPath)
+	if err != nil {
+		return outcome, err
+	}
+
+	file.Status = report.StatusConverted
+	return outcome, nil
+}
+
+// newProgress returns a function rendering the progress of a conversion
+// at the end of the line given if show is true, and emitting it as an
+// event if the emitter is enabled, together with a function to clear
+// the rendered progress. The progress function is nil if the progress
+// is neither shown nor emitted.
+func (p *Processor) newProgress(line string, duration time.Duration,
+	show bool, inputPath string) (
+	onProgress func(progress ffmpeg.Progress), clearProgress func()) {
+	if !show && !p.emitter.Enabled() {
+		return nil, func() {}
+	}
+
+	clearProgress = func() {}
+	var progressLine *progress.Line
+	if show {
+		progressLine = progress.New(p.w, line, duration)
+		clearProgress = progressLine.Clear
+	}
+
+	onProgress = func(progress ffmpeg.Progress) {
+		if progressLine != nil {
+			progressLine.Update(progress)
+		}
+		p.emitter.FileProgress(inputPath, models.MediaTypeVideo, progress, duration)
+	}
+	return onProgress, clearProgress
+}
+
+// fitScale returns the scale filter value to use for the input file
+// media information given, together with the resulting resolution,
+// such that the input is only upscaled if upscale is true.
+func fitScale(info ffprobe.Info, scaleValue string, upscale bool) (
+	effectiveScale string, resolution scale.Resolution, err error) {
+	return scale.Fit(scaleValue, sourceResolution(info), upscale)
+}
+
+// sourceResolution returns the display resolution of the first video
+// stream of the input file media information given, or the zero
+// resolution if it has no video stream.
+func sourceResolution(info ffprobe.Info) (source scale.Resolution) {
+	videoStreams := info.VideoStreams()
+	if len(videoStreams) > 0 {
+		source.Width, source.Height = videoStreams[0].DisplaySize()
+	}
+	return source
+}
+
+// usePalette returns true if the image file given should be stored with
+// a palette, which is if the `png` codec palette setting is enabled,
+// the image is not resized, since resizing creates new colors, and
+// the image colors fit losslessly in a palette.
+func usePalette(image config.Image, inputPath string, info ffprobe.Info,
+	resolution scale.Resolution) (use bool, err error) {
+	if image.Codec != "png" || !*image.PNGPalette ||
+		resolution != sourceResolution(info) {
+		return false, nil
+	}
+
+	fits, err := palette.Fits(inputPath)
+	if err != nil {
+		return false, fmt.Errorf("inspecting image colors: %w", err)
+	}
+	return fits, nil
+}
+
+// hasAlpha returns true if the first video stream of the input file
+// media information given has an alpha channel. For palette images,
+// the palette of the input file is checked for transparent colors.
+func hasAlpha(info ffprobe.Info, inputPath string) (alpha bool, err error) {
+	videoStreams := info.VideoStreams()
+	switch {
+	case len(videoStreams) == 0 || !videoStreams[0].HasAlpha():
+		return false, nil
+	case !videoStreams[0].HasPalette():
+		return true, nil
+	}
+
+	transparent, err := palette.Transparent(inputPath)
+	if err != nil {
+		return false, fmt.Errorf("inspecting image palette: %w", err)
+	}
+	return transparent, nil
+}
+
+func resolutionString(resolution scale.Resolution) string {
+	if resolution == (scale.Resolution{}) {
+		return ""
+	}
+	return "📐 " + resolution.String() + " "
+}
+
+// copyAlreadyTiny copies the input file as is to the output directory,
+// since it is already efficiently encoded. In in-place mode, the
+// input file is left untouched.
+func (p *Processor) copyAlreadyTiny(settings config.Settings, inputPath string,
+	file *report.File) (outcome string, err error) {
+	if *settings.InPlace {
+		stat, err := os.Stat(inputPath)
+		if err != nil {
+			return "", err
+		}
+		file.OutputPath = inputPath
+		file.Status = report.StatusAlreadyTiny
+		file.SetSizes(stat.Size(), stat.Size())
+		p.stats.AddSizes(file.InputSize, file.OutputSize)
+		return alreadyTinyKept, nil
+	}
+
+	outcome, err = p.doOther(settings, inputPath, file)
+	if err != nil || file.Status != report.StatusCopied {
+		return outcome, err
+	}
+
+	file.Status = report.StatusAlreadyTiny
+	p.stats.AddSizes(file.InputSize, file.OutputSize)
+	return alreadyTiny, nil
+}
+
+// outputPaths returns the temporary and final output paths for the
+// input path and output extension given, which are next to the input
+// file in in-place mode.
+func outputPaths(settings config.Settings, inputPath, outputExt string) (
+	outputTempPath, outputPath string) {
+	if *settings.InPlace {
+		return path.InPlace(inputPath, outputExt)
+	}
+	return path.InputToOutput(inputPath, settings.OutputDirPath, outputExt)
+}
+
+// outputExists returns true if the output file exists and must not
+// be overridden. In in-place mode, the output path can be the input
+// path, in which case it is always replaced. Otherwise, an existing
+// file at the output path is another original file, which is never
+// replaced, whatever the override output setting.
+func outputExists(settings config.Settings, inputPath, outputPath string) (
+	exists bool, err error) {
+	if outputPath == inputPath ||
+		(*settings.OverrideOutput && !*settings.InPlace) {
+		return false, nil
+	}
+	return path.DoesFileExist(outputPath)
+}
+
+// finish verifies the converted temporary output file given and checks
+// its size, and renames it to the output path given, or replaces the
+// input file with it in in-place mode. If the verification fails, the
+// input file is used as output, or kept as is in in-place mode, and
+// the verification error is returned.
+func (p *Processor) finish(ctx context.Context, settings config.Settings,
+	inputPath string, inputInfo ffprobe.Info, tempOutputPath, outputPath string,
+	file *report.File) (outcome string, err error) {
+	err = p.verifyOutput(ctx, inputInfo, tempOutputPath, file.MediaType)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return p.fallBack(settings, inputPath, tempOutputPath, outputPath, file,
+			fmt.Errorf("verifying output file: %w", err))
+	}
+
+	if *settings.InPlace {
+		return p.replaceInPlace(settings, inputPath, tempOutputPath, outputPath, file)
+	}
+
+	outcome, err = p.sizeCheck(settings, inputPath, tempOutputPath, file)
+	if err != nil {
+		return "", err
+	}
+
+	err = filetime.Copy(tempOutputPath, inputPath)
+	if err != nil {
+		return outcome, err
+	}
+
+	err = os.Rename(tempOutputPath, outputPath)
+	if err != nil {
+		return outcome, fmt.Errorf("renaming temp output file to final output file: %w", err)
+	}
+	return outcome, nil
+}
+
+var ErrOutputEmpty = errors.New("output file is empty")
+
+// verifyOutput checks the output file given is not empty and can be
+// fully decoded, and that its media information matches the input
+// media information given.
+func (p *Processor) verifyOutput(ctx context.Context, inputInfo ffprobe.Info,
+	outputPath string, mediaType models.MediaType) (err error) {
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	} else if stat.Size() == 0 {
+		return ErrOutputEmpty
+	}
+
+	err = p.ffmpeg.Decode(ctx, outputPath)
+	if err != nil {
+		return err
+	}
+
+	outputInfo, err := p.ffprobe.Probe(ctx, outputPath)
+	if err != nil {
+		return fmt.Errorf("probing output file: %w", err)
+	}
+
+	return verify.Compare(inputInfo, outputInfo, mediaType)
+}
+
+// fallBack uses the input file as output in place of the temporary
+// output file given, or keeps the input file as is in in-place mode,
+// and returns the error given, after the error of the fall back if any.
+func (p *Processor) fallBack(settings config.Settings, inputPath,
+	tempOutputPath, outputPath string, file *report.File, failure error) (
+	outcome string, err error) {
+	stat, err := os.Stat(inputPath)
+	if err != nil {
+		return "", errors.Join(failure, err)
+	}
+	p.stats.AddSizes(stat.Size(), stat.Size())
+	file.SetSizes(stat.Size(), stat.Size())
+	file.InputKept = true
+
+	if *settings.InPlace {
+		file.OutputPath = inputPath
+		return "😑 Keeping original ✔️", failure
+	}
+
+	outcome = "😑 Replacing output with input..."
+	err = size.ReplaceBy(tempOutputPath, inputPath)
+	if err != nil {
+		return outcome, errors.Join(failure, err)
+	}
+
+	err = filetime.Copy(tempOutputPath, inputPath)
+	if err != nil {
+		return outcome, errors.Join(failure, err)
+	}
+
+	err = os.Rename(tempOutputPath, outputPath)
+	if err != nil {
+		return outcome, errors.Join(failure,
+			fmt.Errorf("renaming temp output file to final output file: %w", err))
+	}
+	return outcome + " ✔️", failure
+}
+
+// replaceInPlace checks the temporary output file given is smaller
+// than the input file, and then atomically renames it to the output
+// path given next to the input file, removing the input file if the
+// output path differs. The input file modification time and permissions
+// are preserved, and the input file is copied to the backup directory
+// first if it is set. If the output file is larger, it is discarded
+// and the input file is kept as is.
+func (p *Processor) replaceInPlace(settings config.Settings,
+	inputPath, tempOutputPath, outputPath string, file *report.File) (
+	outcome string, err error) {
+	outcome, err = p.sizeCheck(settings, inputPath, tempOutputPath, file)
+	if err != nil {
+		return "", err
+	} else if file.InputKept {
+		file.OutputPath = inputPath
+		return outcome, nil
+	}
+
+	inputStat, err := os.Stat(inputPath)
+	if err != nil {
+		return outcome, err
+	}
+
+	err = os.Chmod(tempOutputPath, inputStat.Mode().Perm())
+	if err != nil {
+		return outcome, fmt.Errorf("setting output file permissions: %w", err)
+	}
+
+	err = filetime.Copy(tempOutputPath, inputPath)
+	if err != nil {
+		return outcome, err
+	}
+
+	if *settings.BackupDirPath != "" {
+		err = backup(settings, inputPath)
+		if err != nil {
+			return outcome, fmt.Errorf("backing up original file: %w", err)
+		}
+	}
+
+	err = os.Rename(tempOutputPath, outputPath)
+	if err != nil {
+		return outcome, fmt.Errorf("renaming temp output file over original file: %w", err)
+	}
+
+	if outputPath != inputPath {
+		err = os.Remove(inputPath)
+		if err != nil {
+			return outcome, fmt.Errorf("removing original file: %w", err)
+		}
+	}
+	return outcome, nil
+}
+
+// backup copies the file at the input path given to the same relative
+// path in the backup directory, preserving its modification time.
+func backup(settings config.Settings, inputPath string) (err error) {
+	relativePath, err := filepath.Rel(settings.InputDirPath, inputPath)
+	if err != nil {
+		return fmt.Errorf("getting path relative to input directory: %w", err)
+	}
+	backupPath := filepath.Join(*settings.BackupDirPath, relativePath)
+	const dirPerms os.FileMode = 0700
+	err = os.MkdirAll(filepath.Dir(backupPath), dirPerms)
+	if err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	err = size.ReplaceBy(backupPath, inputPath)
+	if err != nil {
+		return err
+	}
+
+	return filetime.Copy(backupPath, inputPath)
+}
+
+// sizeCheck compares the sizes of the input and output files given.
+// If the output file is larger, it is replaced by the input file, or,
+// in in-place mode, left to be discarded.
+func (p *Processor) sizeCheck(settings config.Settings, inputPath, outputPath string,
+	file *report.File) (outcome string, err error) {
+	inputSize, outputSize, err := size.GetSizes(inputPath, outputPath)
+	if err != nil {
+		return "", err
+	}
+
+	outcome = "✔️  (" + size.DiffString(outputSize, inputSize) + ")"
+
+	if outputSize <= inputSize {
+		p.stats.AddSizes(inputSize, outputSize)
+		file.SetSizes(inputSize, outputSize)
+		return outcome, nil
+	}
+
+	if *settings.InPlace {
+		p.stats.AddSizes(inputSize, inputSize)
+		file.SetSizes(inputSize, inputSize)
+		file.InputKept = true
+		return outcome + " 😑 Keeping original ✔️", nil
+	}
+
+	outcome += " 😑 Replacing output with input..."
+	err = size.ReplaceBy(outputPath, inputPath)
+	if err != nil {
+		p.stats.AddSizes(inputSize, outputSize)
+		file.SetSizes(inputSize, outputSize)
+		return outcome, err
+	}
+	p.stats.AddSizes(inputSize, inputSize)
+	file.SetSizes(inputSize, inputSize)
+	file.InputKept = true
+	outcome += " ✔️"
+	return outcome, nil
+}
+
+func warnSignErr(err error) string {
+	return " ⚠️  " + err.Error()
+}
+
+// syncWriter serializes writes to its writer, such that lines
+// written in a single write call by concurrent workers do not
+// interleave.
+type syncWriter struct {
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+func (s *syncWriter) Write(p []byte) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writer.Write(p)
+}
@@ -0,0 +1,20 @@
+package tinier
+
+import "net/http"
+
+type Logger interface {
+	Debug(msg string)
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+}
+
+// EventSink receives the events of a processor,
+// and must be safe for concurrent use.
+type EventSink interface {
+	Emit(event Event)
+}
+
+type HTTPClient interface {
+	Do(request *http.Request) (response *http.Response, err error)
+}
@@ -0,0 +1,9 @@
+package tinier
+
+// noopLogger is the logger used if no logger is given.
+type noopLogger struct{}
+
+func (noopLogger) Debug(string) {}
+func (noopLogger) Info(string)  {}
+func (noopLogger) Warn(string)  {}
+func (noopLogger) Error(string) {}
@@ -0,0 +1,234 @@
+package tinier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/qdm12/tinier/internal/path"
+	"github.com/qdm12/tinier/internal/pool"
+	"github.com/qdm12/tinier/internal/report"
+)
+
+const (
+	fileAlreadyExists = "✔️  file already exists"
+	alreadyDone       = "✔️  already done in a previous run"
+	alreadyTiny       = "✔️  already tiny, copied as is"
+	alreadyTinyKept   = "✔️  already tiny, kept as is"
+)
+
+// process processes the files given by media type, one media type
+// at a time, and returns their results.
+func (p *Processor) process(ctx context.Context,
+	imagePaths, audioPaths, videoPaths, otherPaths []string) (
+	results []Result, err error) {
+	var mutex sync.Mutex
+	add := func(result Result) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		results = append(results, result)
+	}
+
+	mediaTypePaths := []struct {
+		mediaType models.MediaType
+		paths     []string
+	}{
+		{mediaType: models.MediaTypeOther, paths: otherPaths},
+		{mediaType: models.MediaTypeAudio, paths: audioPaths},
+		{mediaType: models.MediaTypeImage, paths: imagePaths},
+		{mediaType: models.MediaTypeVideo, paths: videoPaths},
+	}
+	for _, mediaTypePath := range mediaTypePaths {
+		p.processMediaType(ctx, mediaTypePath.mediaType, mediaTypePath.paths, add)
+		if err = ctx.Err(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// processMediaType processes concurrently the files given of the
+// media type given, adds the result of each file to the report and
+// calls add with it.
+func (p *Processor) processMediaType(ctx context.Context, mediaType models.MediaType,
+	inputPaths []string, add func(result Result)) {
+	skip, workers := p.mediaTypeSettings(mediaType)
+	if skip {
+		fmt.Fprintf(p.w, "⚠️ Skipping %s files\n", mediaType)
+		return
+	}
+	// The video progress is only shown if videos are processed one at
+	// a time, since concurrent progress lines would overwrite each other.
+	showProgress := workers == 1
+	pool.Run(ctx, workers, inputPaths, func(inputPath string) {
+		result, _ := p.processFile(ctx, inputPath, mediaType, showProgress)
+		p.reporter.Add(result)
+		add(result)
+	})
+}
+
+// mediaTypeSettings returns whether files of the media type given
+// are skipped, and the maximum number of them to process concurrently.
+func (p *Processor) mediaTypeSettings(mediaType models.MediaType) (
+	skip bool, workers uint) {
+	switch mediaType {
+	case models.MediaTypeImage:
+		return *p.settings.Image.Skip, *p.settings.Image.Workers
+	case models.MediaTypeAudio:
+		return *p.settings.Audio.Skip, *p.settings.Audio.Workers
+	case models.MediaTypeVideo:
+		return *p.settings.Video.Skip, *p.settings.Video.Workers
+	case models.MediaTypeOther:
+		// Other files are only copied to the output directory,
+		// so there is nothing to do with them in in-place mode.
+		return *p.settings.InPlace, *p.settings.Workers
+	default:
+		panic(fmt.Sprintf("media type %q not implemented", mediaType))
+	}
+}
+
+// mediaType returns the media type of the file path given,
+// depending on its file extension.
+func (p *Processor) mediaType(filePath string) models.MediaType {
+	imagePaths, audioPaths, videoPaths, _ := path.Split([]string{filePath},
+		p.settings.Image.Extensions, p.settings.Audio.Extensions,
+		p.settings.Video.Extensions)
+	switch {
+	case len(imagePaths) > 0:
+		return models.MediaTypeImage
+	case len(audioPaths) > 0:
+		return models.MediaTypeAudio
+	case len(videoPaths) > 0:
+		return models.MediaTypeVideo
+	default:
+		return models.MediaTypeOther
+	}
+}
+
+// processFile processes the file at the input path given and writes
+// its outcome as a line to the human readable output. For videos,
+// the conversion progress is shown live if showProgress is true,
+// which must only be the case if no other file is processed at
+// the same time.
+func (p *Processor) processFile(ctx context.Context, inputPath string,
+	mediaType models.MediaType, showProgress bool) (result Result, err error) {
+	if mediaType == models.MediaTypeVideo {
+		return p.processVideoFile(ctx, inputPath, showProgress)
+	}
+
+	line := fmt.Sprintf("🗜️  Tinying %s ... ", inputPath)
+	if mediaType == models.MediaTypeOther {
+		line = fmt.Sprintf("🗄️  Copying %s ... ", inputPath)
+	}
+
+	result, outcome, err := p.doJournaled(ctx, inputPath, mediaType,
+		func(settings config.Settings, file *report.File) (outcome string, err error) {
+			switch mediaType {
+			case models.MediaTypeImage:
+				return p.doImage(ctx, settings, inputPath, file)
+			case models.MediaTypeAudio:
+				return p.doAudio(ctx, settings, inputPath, file)
+			default:
+				return p.doOther(settings, inputPath, file)
+			}
+		})
+	if err != nil {
+		p.stats.AddFailure()
+		outcome += warnSignErr(err)
+	}
+	fmt.Fprintln(p.w, line+outcome)
+	return result, err
+}
+
+func (p *Processor) processVideoFile(ctx context.Context, inputPath string,
+	showProgress bool) (result Result, err error) {
+	line := fmt.Sprintf("🗜️  Tinying %s ...", inputPath)
+	if showProgress {
+		fmt.Fprint(p.w, line)
+	}
+	result, outcome, err := p.doJournaled(ctx, inputPath, models.MediaTypeVideo,
+		func(settings config.Settings, file *report.File) (outcome string, err error) {
+			return p.doVideo(ctx, settings, inputPath, file, line, showProgress)
+		})
+	if err != nil {
+		p.stats.AddFailure()
+		outcome += "  ⚠️  " + err.Error()
+	}
+	if !showProgress {
+		outcome = line + outcome
+	}
+	fmt.Fprintln(p.w, outcome)
+	return result, err
+}
+
+// doJournaled resolves the settings for the input path given, calls do
+// with these settings and records its outcome in the journal, emitting
+// events when it starts and finishes. In resume mode,
+// it skips input paths recorded as done and forces overriding the output
+// of stale input paths. In in-place mode, it always resumes and records
+// the file replacing the input file, so it is not processed again.
+func (p *Processor) doJournaled(ctx context.Context,
+	inputPath string, mediaType models.MediaType,
+	do func(settings config.Settings, file *report.File) (outcome string, err error)) (
+	file report.File, outcome string, err error) {
+	file = report.File{
+		InputPath: inputPath,
+		MediaType: mediaType,
+	}
+	p.emitter.FileStarted(inputPath, mediaType)
+	start := time.Now()
+	defer func() {
+		file.Seconds = time.Since(start).Seconds()
+		if err != nil {
+			file.Status = report.StatusFailed
+			file.Error = err.Error()
+		}
+		p.emitter.FileDone(file)
+	}()
+
+	settings, err := p.dirResolver.Resolve(inputPath, mediaType)
+	if err != nil {
+		return file, "", fmt.Errorf("resolving directory settings: %w", err)
+	}
+
+	fingerprint := settings.Fingerprint(mediaType)
+	file.Settings = fingerprint
+
+	input, done, stale, err := p.journal.Check(inputPath, fingerprint)
+	if err != nil {
+		return file, "", fmt.Errorf("checking journal: %w", err)
+	}
+
+	if *settings.Resume || *settings.InPlace {
+		if done {
+			file.Status = report.StatusSkippedDone
+			return file, alreadyDone, nil
+		} else if stale { // output from a previous run is outdated
+			override := true
+			settings.OverrideOutput = &override
+		}
+	}
+
+	outcome, err = do(settings, &file)
+	if ctx.Err() != nil { // interrupted, do not record it
+		return file, outcome, err
+	}
+
+	if err == nil && *settings.InPlace &&
+		(file.Status == report.StatusConverted || file.Status == report.StatusAlreadyTiny) {
+		input, _, _, err = p.journal.Check(file.OutputPath, fingerprint)
+		if err != nil {
+			return file, outcome, fmt.Errorf("checking journal: %w", err)
+		}
+	}
+
+	recordErr := p.journal.Record(input, outcome, err)
+	if recordErr != nil {
+		err = errors.Join(err, fmt.Errorf("recording in journal: %w", recordErr))
+	}
+	return file, outcome, err
+}
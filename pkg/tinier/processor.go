@@ -0,0 +1,324 @@
+package tinier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/qdm12/tinier/internal/cmd"
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/events"
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/qdm12/tinier/internal/journal"
+	"github.com/qdm12/tinier/internal/path"
+	"github.com/qdm12/tinier/internal/report"
+	"github.com/qdm12/tinier/internal/semver"
+	"github.com/qdm12/tinier/internal/stats"
+	"github.com/qdm12/tinier/internal/watch"
+)
+
+// Processor converts and copies files to its output directory,
+// recording them in the journal of the output directory.
+// Its methods are safe for concurrent use, except for Watch.
+type Processor struct {
+	settings config.Settings
+	// rawSettings are the settings before their defaults are set,
+	// to merge directory settings files onto.
+	rawSettings  config.Settings
+	capabilities config.Capabilities
+	dirResolver  *config.DirResolver
+	journal      *journal.Journal
+	ffmpeg       *ffmpeg.FFMPEG
+	ffprobe      *ffprobe.FFProbe
+	stats        *stats.Stats
+	reporter     *report.Report
+	emitter      *events.Emitter
+	logger       Logger
+	// w is the writer for the human readable output.
+	w io.Writer
+}
+
+var (
+	ErrVMAFUnavailable    = errors.New("ffmpeg is not built with libvmaf for the VMAF metric")
+	ErrEncoderUnavailable = config.ErrEncoderUnavailable
+	ErrMuxerUnavailable   = config.ErrMuxerUnavailable
+	ErrCodecContainer     = config.ErrCodecContainer
+)
+
+// New creates a processor from the settings given, setting their
+// defaults and validating them. It finds or downloads ffmpeg using
+// the HTTP client given, and creates the output directory unless
+// in in-place mode.
+// The logger, event sink and writer for the human readable
+// output are optional and can be nil.
+// The processor must be closed with Close once done.
+func New(ctx context.Context, settings Settings, httpClient HTTPClient,
+	logger Logger, sink EventSink, w io.Writer) (processor *Processor, err error) {
+	rawSettings, err := prepareSettings(&settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	if w == nil {
+		w = io.Discard
+	}
+	// Lines are written concurrently by the workers.
+	w = &syncWriter{writer: w}
+
+	cmd := cmd.New()
+	minVersion := semver.MustParse(settings.FfmpegMinVersion)
+	ffmpegPath, err := ffmpeg.SetupFFMPEG(ctx, minVersion,
+		*settings.FfmpegPath, cmd, httpClient, w)
+	if err != nil {
+		fmt.Fprintln(w, "❌")
+		return nil, fmt.Errorf("failed to setup ffmpeg: %w", err)
+	}
+
+	ffmpeg := ffmpeg.New(cmd, ffmpegPath, minVersion, logger)
+
+	err = ffmpeg.DetectCapabilities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("detecting ffmpeg capabilities: %w", err)
+	}
+	capabilities := ffmpeg.Capabilities()
+
+	warnings, err := settings.ValidateCapabilities(capabilities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings for %s: %w", ffmpegPath, err)
+	}
+	for _, warning := range warnings {
+		logger.Warn(warning)
+	}
+
+	if needsVMAF(settings) && !capabilities.HasFilter("libvmaf") {
+		return nil, fmt.Errorf("%w: %s", ErrVMAFUnavailable, ffmpegPath)
+	}
+
+	ffprobePath, err := ffprobe.Find(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("finding ffprobe: %w", err)
+	}
+	logger.Debug("using ffprobe at " + ffprobePath)
+	ffprobe := ffprobe.New(cmd, ffprobePath, logger)
+
+	if !*settings.InPlace {
+		fmt.Fprintf(w, "📁 Creating output directory %s if needed... ", settings.OutputDirPath)
+		const dirPerms fs.FileMode = 0700
+		err = os.MkdirAll(settings.OutputDirPath, dirPerms)
+		if err != nil {
+			fmt.Fprintln(w, "❌")
+			return nil, err
+		}
+		fmt.Fprintln(w, "✔️")
+	}
+
+	journal, err := journal.Open(journalPath(settings))
+	if err != nil {
+		return nil, fmt.Errorf("opening journal: %w", err)
+	}
+
+	var eventsSink events.Sink
+	if sink != nil {
+		eventsSink = sink
+	}
+
+	return &Processor{
+		settings:     settings,
+		rawSettings:  rawSettings,
+		capabilities: capabilities,
+		dirResolver:  config.NewDirResolver(settings, rawSettings, capabilities, logger),
+		journal:      journal,
+		ffmpeg:       ffmpeg,
+		ffprobe:      ffprobe,
+		stats:        stats.New(),
+		reporter:     report.New(),
+		emitter:      events.New(eventsSink),
+		logger:       logger,
+		w:            w,
+	}, nil
+}
+
+// needsVMAF returns true if the settings given use the VMAF metric,
+// either for the quality check or for the video CRF search.
+func needsVMAF(settings Settings) bool {
+	return settings.Quality.Metric == config.QualityMetricVMAF ||
+		(settings.Video.CRFSearch() && settings.Video.TargetMetric == config.QualityMetricVMAF)
+}
+
+// journalPath returns the journal file path, which is in the output
+// directory, or in the input directory in in-place mode.
+func journalPath(settings Settings) string {
+	if *settings.InPlace {
+		return filepath.Join(settings.InputDirPath, journal.Filename)
+	}
+	return filepath.Join(settings.OutputDirPath, journal.Filename)
+}
+
+// PrepareSettings applies the profile of the settings given,
+// sets their defaults and validates them. It is called by New
+// and DryRun, and can be used to show the settings beforehand.
+func PrepareSettings(settings *Settings) (err error) {
+	_, err = prepareSettings(settings)
+	return err
+}
+
+// prepareSettings prepares the settings given as PrepareSettings
+// does, and returns the settings with their profile applied but
+// before their defaults are set.
+func prepareSettings(settings *Settings) (raw Settings, err error) {
+	err = settings.ApplyProfile()
+	if err != nil {
+		return raw, fmt.Errorf("applying profile: %w", err)
+	}
+	raw = *settings
+
+	settings.SetDefaults()
+	err = settings.Validate()
+	if err != nil {
+		return raw, fmt.Errorf("invalid settings: %w", err)
+	}
+	return raw, nil
+}
+
+// Close closes the journal of the processor.
+func (p *Processor) Close() (err error) {
+	return p.journal.Close()
+}
+
+// Run processes the input directory and keeps on watching it for
+// new or modified files if the watch setting is enabled. It emits
+// the run_started and run_finished events, and writes the run
+// statistics to the human readable output once done.
+func (p *Processor) Run(ctx context.Context) (err error) {
+	p.emitter.RunStarted(p.settings)
+	defer func() {
+		p.stats.Finish(p.w)
+		p.emitter.RunFinished(p.stats, err)
+	}()
+
+	_, err = p.ProcessDir(ctx, p.settings.InputDirPath)
+	if err != nil || !*p.settings.Watch {
+		return err
+	}
+
+	return p.Watch(ctx)
+}
+
+// ProcessDir processes all the files in the directory given and its
+// subdirectories, and writes the run report if its path is set.
+// It returns the results of the files processed.
+func (p *Processor) ProcessDir(ctx context.Context, dirPath string) (
+	results []Result, err error) {
+	imagePaths, audioPaths, videoPaths, otherPaths, err := readDir(p.w, dirPath, p.settings)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err = p.process(ctx, imagePaths, audioPaths, videoPaths, otherPaths)
+	reportErr := p.writeReport()
+	return results, errors.Join(err, reportErr)
+}
+
+var ErrMediaTypeSkipped = errors.New("media type is skipped")
+
+// ProcessFile processes the file at the path given, depending
+// on its media type determined from its file extension. The file
+// is not added to the run report, since it is not part of a run,
+// and the video conversion progress is not shown, since files may
+// be processed concurrently.
+// It returns an error wrapping ErrMediaTypeSkipped if the
+// settings skip its media type.
+func (p *Processor) ProcessFile(ctx context.Context, filePath string) (
+	result Result, err error) {
+	mediaType := p.mediaType(filePath)
+	skip, _ := p.mediaTypeSettings(mediaType)
+	if skip {
+		return result, fmt.Errorf("%w: %s", ErrMediaTypeSkipped, mediaType)
+	}
+	const showProgress = false
+	return p.processFile(ctx, filePath, mediaType, showProgress)
+}
+
+// Watch watches the input directory for new or modified files and
+// processes them, until the context is canceled. The files are
+// processed in resume mode, since they may have been processed
+// already. It must not be called concurrently with other methods.
+func (p *Processor) Watch(ctx context.Context) (err error) {
+	fmt.Fprintf(p.w, "👀 Watching input directory %s for new files...\n",
+		p.settings.InputDirPath)
+	watcher := watch.New(p.settings.InputDirPath, p.settings.WatchStabilityPeriod,
+		p.logger, p.settings.OutputDirPath)
+	// Files seen by the watcher may have been processed already or
+	// modified since they were processed, so resume from the journal
+	// to skip or override them.
+	resume := true
+	p.settings.Resume = &resume
+	p.dirResolver = config.NewDirResolver(p.settings, p.rawSettings,
+		p.capabilities, p.logger)
+	err = watcher.Run(ctx, func(filePaths []string) {
+		filePaths, found := config.RemoveDirFiles(filePaths)
+		if found {
+			p.dirResolver.Reset()
+		}
+		imagePaths, audioPaths, videoPaths, otherPaths := path.Split(filePaths,
+			p.settings.Image.Extensions, p.settings.Audio.Extensions,
+			p.settings.Video.Extensions)
+		_, _ = p.process(ctx, imagePaths, audioPaths, videoPaths, otherPaths)
+		err := p.writeReport()
+		if err != nil {
+			p.logger.Error(err.Error())
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("watching input directory: %w", err)
+	}
+	return ctx.Err()
+}
+
+// readDir returns the file paths in the directory given and its
+// subdirectories split by media type, without the directory
+// settings files, and writes how many were found to w.
+func readDir(w io.Writer, dirPath string, settings Settings) (
+	imagePaths, audioPaths, videoPaths, otherPaths []string, err error) {
+	fmt.Fprintf(w, "📁 Reading input directory %s... ", dirPath)
+	imagePaths, audioPaths, videoPaths, otherPaths, err = path.Walk(
+		dirPath, settings.Image.Extensions,
+		settings.Audio.Extensions, settings.Video.Extensions)
+	if err != nil {
+		fmt.Fprintln(w, "❌")
+		return nil, nil, nil, nil, err
+	}
+	otherPaths, _ = config.RemoveDirFiles(otherPaths)
+
+	fmt.Fprintf(w,
+		"%d image(s), %d audio file(s) and %d video(s) found\n",
+		len(imagePaths), len(audioPaths), len(videoPaths))
+	return imagePaths, audioPaths, videoPaths, otherPaths, nil
+}
+
+// writeReport writes the report together with the totals of the
+// stats to the report path, if it is set.
+func (p *Processor) writeReport() (err error) {
+	if *p.settings.ReportPath == "" {
+		return nil
+	}
+
+	failures, inputSize, outputSize := p.stats.Totals()
+	err = p.reporter.WriteFile(*p.settings.ReportPath, failures, inputSize, outputSize)
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,612 @@
+//go:build !windows
+
+package tinier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeFFMPEG = `#!/bin/sh
+if [ "$1" = "-version" ]; then
+	echo "ffmpeg version 6.0.1 Copyright (c) fake"
+	exit 0
+fi
+case "$2" in
+	-encoders)
+		echo " V....D libsvtav1            SVT-AV1(Scalable Video Technology for AV1) encoder (codec av1)"
+		echo " V....D mjpeg                MJPEG (Motion JPEG)"
+		echo " V....D png                  PNG (Portable Network Graphics) image"
+		echo " A....D libopus              libopus Opus (codec opus)"
+		exit 0 ;;
+	-muxers)
+		echo "  E image2          image2 sequence"
+		echo "  E mp4             MP4 (MPEG-4 Part 14)"
+		echo "  E opus            Ogg Opus"
+		exit 0 ;;
+	-filters) exit 0 ;;
+esac
+for arg; do
+	case "$arg" in
+		null) exit 0 ;; # decoding to verify the output
+		*.jpg|*.png) output="$arg" ;;
+	esac
+done
+printf tiny > "$output"
+`
+
+const fakeFFProbe = `#!/bin/sh
+echo '{"streams":[{"index":0,"codec_type":"video","codec_name":"png",` +
+	`"width":640,"height":480,"avg_frame_rate":"0/0","r_frame_rate":"25/1"}],` +
+	`"format":{"format_name":"png_pipe"}}'
+`
+
+// writeFakeFFMPEG writes fake ffmpeg and ffprobe scripts to the
+// directory given, and returns the ffmpeg script path.
+func writeFakeFFMPEG(t *testing.T, dirPath string) (ffmpegPath string) {
+	t.Helper()
+	const perms os.FileMode = 0700
+	ffmpegPath = filepath.Join(dirPath, "ffmpeg")
+	err := os.WriteFile(ffmpegPath, []byte(fakeFFMPEG), perms)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dirPath, "ffprobe"), []byte(fakeFFProbe), perms)
+	require.NoError(t, err)
+	return ffmpegPath
+}
+
+type recordingSink struct {
+	eventTypes []EventType
+	mutex      sync.Mutex
+}
+
+func (r *recordingSink) Emit(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.eventTypes = append(r.eventTypes, event.Type)
+}
+
+func Test_Processor(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	ffmpegPath := writeFakeFFMPEG(t, rootDir)
+
+	inputDir := filepath.Join(rootDir, "input")
+	const dirPerms os.FileMode = 0700
+	err := os.Mkdir(inputDir, dirPerms)
+	require.NoError(t, err)
+	const filePerms os.FileMode = 0600
+	imagePath := filepath.Join(inputDir, "a.png")
+	err = os.WriteFile(imagePath, []byte("large png image data"), filePerms)
+	require.NoError(t, err)
+	otherPath := filepath.Join(inputDir, "b.txt")
+	err = os.WriteFile(otherPath, []byte("text"), filePerms)
+	require.NoError(t, err)
+
+	settings := Settings{
+		InputDirPath:  inputDir,
+		OutputDirPath: filepath.Join(rootDir, "output"),
+		FfmpegPath:    &ffmpegPath,
+	}
+	sink := &recordingSink{}
+
+	ctx := context.Background()
+	processor, err := New(ctx, settings, nil, nil, sink, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := processor.Close()
+		assert.NoError(t, err)
+	})
+
+	results, err := processor.ProcessDir(ctx, inputDir)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].InputPath < results[j].InputPath
+	})
+
+	assert.Equal(t, imagePath, results[0].InputPath)
+	assert.Equal(t, MediaTypeImage, results[0].MediaType)
+	assert.Equal(t, StatusConverted, results[0].Status)
+	assert.Equal(t, int64(len("tiny")), results[0].OutputSize)
+	data, err := os.ReadFile(results[0].OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "tiny", string(data))
+
+	assert.Equal(t, otherPath, results[1].InputPath)
+	assert.Equal(t, MediaTypeOther, results[1].MediaType)
+	assert.Equal(t, StatusCopied, results[1].Status)
+
+	result, err := processor.ProcessFile(ctx, imagePath)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSkippedExisting, result.Status)
+
+	expectedEventTypes := []EventType{
+		EventFileStarted, EventFileFinished,
+		EventFileStarted, EventFileFinished,
+		EventFileStarted, EventFileSkipped,
+	}
+	assert.Equal(t, expectedEventTypes, sink.eventTypes)
+}
+
+func Test_Processor_inPlace(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	ffmpegPath := writeFakeFFMPEG(t, rootDir)
+
+	inputDir := filepath.Join(rootDir, "input")
+	const dirPerms os.FileMode = 0700
+	err := os.Mkdir(inputDir, dirPerms)
+	require.NoError(t, err)
+	const filePerms os.FileMode = 0640
+	imagePath := filepath.Join(inputDir, "a.png")
+	err = os.WriteFile(imagePath, []byte("large png image data"), filePerms)
+	require.NoError(t, err)
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = os.Chtimes(imagePath, modTime, modTime)
+	require.NoError(t, err)
+	otherPath := filepath.Join(inputDir, "b.txt")
+	err = os.WriteFile(otherPath, []byte("text"), filePerms)
+	require.NoError(t, err)
+
+	inPlace := true
+	backupDir := filepath.Join(rootDir, "backup")
+	outputDir := filepath.Join(rootDir, "output")
+	settings := Settings{
+		InputDirPath:  inputDir,
+		OutputDirPath: outputDir,
+		FfmpegPath:    &ffmpegPath,
+		InPlace:       &inPlace,
+		BackupDirPath: &backupDir,
+	}
+
+	ctx := context.Background()
+	processor, err := New(ctx, settings, nil, nil, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := processor.Close()
+		assert.NoError(t, err)
+	})
+
+	results, err := processor.ProcessDir(ctx, inputDir)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	convertedPath := filepath.Join(inputDir, "a.jpg")
+	assert.Equal(t, StatusConverted, results[0].Status)
+	assert.Equal(t, convertedPath, results[0].OutputPath)
+
+	data, err := os.ReadFile(convertedPath)
+	require.NoError(t, err)
+	assert.Equal(t, "tiny", string(data))
+	stat, err := os.Stat(convertedPath)
+	require.NoError(t, err)
+	assert.Equal(t, filePerms, stat.Mode().Perm())
+	assert.True(t, modTime.Equal(stat.ModTime()))
+
+	_, err = os.Stat(imagePath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	data, err = os.ReadFile(filepath.Join(backupDir, "a.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "large png image data", string(data))
+
+	data, err = os.ReadFile(otherPath)
+	require.NoError(t, err)
+	assert.Equal(t, "text", string(data))
+	_, err = os.Stat(outputDir)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	result, err := processor.ProcessFile(ctx, convertedPath)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSkippedDone, result.Status)
+}
+
+func Test_Processor_inPlaceExistingOriginal(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	ffmpegPath := writeFakeFFMPEG(t, rootDir)
+
+	inputDir := filepath.Join(rootDir, "input")
+	const dirPerms os.FileMode = 0700
+	err := os.Mkdir(inputDir, dirPerms)
+	require.NoError(t, err)
+	const filePerms os.FileMode = 0600
+	pngPath := filepath.Join(inputDir, "a.png")
+	err = os.WriteFile(pngPath, []byte("large png image data"), filePerms)
+	require.NoError(t, err)
+	jpgPath := filepath.Join(inputDir, "a.jpg")
+	err = os.WriteFile(jpgPath, []byte("original jpg image data"), filePerms)
+	require.NoError(t, err)
+
+	inPlace := true
+	overrideOutput := true
+	settings := Settings{
+		InputDirPath:   inputDir,
+		OutputDirPath:  filepath.Join(rootDir, "output"),
+		FfmpegPath:     &ffmpegPath,
+		InPlace:        &inPlace,
+		OverrideOutput: &overrideOutput,
+	}
+
+	ctx := context.Background()
+	processor, err := New(ctx, settings, nil, nil, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := processor.Close()
+		assert.NoError(t, err)
+	})
+
+	result, err := processor.ProcessFile(ctx, pngPath)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSkippedExisting, result.Status)
+
+	data, err := os.ReadFile(pngPath)
+	require.NoError(t, err)
+	assert.Equal(t, "large png image data", string(data))
+	data, err = os.ReadFile(jpgPath)
+	require.NoError(t, err)
+	assert.Equal(t, "original jpg image data", string(data))
+}
+
+func Test_Processor_alreadyTinyVideo(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		width  int
+		height int
+		status Status
+	}{
+		"at scale resolution": {
+			width:  1280,
+			height: 720,
+			status: StatusAlreadyTiny,
+		},
+		"above scale resolution": {
+			width:  3840,
+			height: 2160,
+			status: StatusConverted,
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			ffmpegPath := writeFakeFFMPEG(t, rootDir)
+			videoFFMPEG := strings.Replace(fakeFFMPEG,
+				"*.jpg|*.png) output", "*.mp4) output", 1)
+			videoFFProbe := fmt.Sprintf(`#!/bin/sh
+echo '{"streams":[{"index":0,"codec_type":"video","codec_name":"av1",`+
+				`"width":%d,"height":%d,"avg_frame_rate":"25/1","bit_rate":"100000"}],`+
+				`"format":{"format_name":"mp4","duration":"10.0"}}'
+`, testCase.width, testCase.height)
+			const perms os.FileMode = 0700
+			err := os.WriteFile(ffmpegPath, []byte(videoFFMPEG), perms)
+			require.NoError(t, err)
+			err = os.WriteFile(filepath.Join(rootDir, "ffprobe"), []byte(videoFFProbe), perms)
+			require.NoError(t, err)
+
+			inputDir := filepath.Join(rootDir, "input")
+			err = os.Mkdir(inputDir, perms)
+			require.NoError(t, err)
+			videoPath := filepath.Join(inputDir, "a.mp4")
+			err = os.WriteFile(videoPath, []byte("large av1 video data"), perms)
+			require.NoError(t, err)
+
+			settings := Settings{
+				InputDirPath:  inputDir,
+				OutputDirPath: filepath.Join(rootDir, "output"),
+				FfmpegPath:    &ffmpegPath,
+			}
+			settings.Video.Codec = "libsvtav1"
+			settings.Video.Scale = "1280:-1"
+
+			ctx := context.Background()
+			processor, err := New(ctx, settings, nil, nil, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				err := processor.Close()
+				assert.NoError(t, err)
+			})
+
+			result, err := processor.ProcessFile(ctx, videoPath)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.status, result.Status)
+		})
+	}
+}
+
+func Test_Processor_verificationFailure(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	ffmpegPath := writeFakeFFMPEG(t, rootDir)
+	// ffmpeg logs decoding errors but exits successfully
+	// for a truncated output file.
+	corruptFFMPEG := strings.Replace(fakeFFMPEG,
+		"null) exit 0 ;;", `null) echo "Invalid data found"; exit 0 ;;`, 1)
+	const perms os.FileMode = 0700
+	err := os.WriteFile(ffmpegPath, []byte(corruptFFMPEG), perms)
+	require.NoError(t, err)
+
+	inputDir := filepath.Join(rootDir, "input")
+	err = os.Mkdir(inputDir, perms)
+	require.NoError(t, err)
+	const inputData = "large png image data"
+	imagePath := filepath.Join(inputDir, "a.png")
+	err = os.WriteFile(imagePath, []byte(inputData), perms)
+	require.NoError(t, err)
+
+	settings := Settings{
+		InputDirPath:  inputDir,
+		OutputDirPath: filepath.Join(rootDir, "output"),
+		FfmpegPath:    &ffmpegPath,
+	}
+
+	ctx := context.Background()
+	processor, err := New(ctx, settings, nil, nil, nil, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := processor.Close()
+		assert.NoError(t, err)
+	})
+
+	result, err := processor.ProcessFile(ctx, imagePath)
+	require.ErrorIs(t, err, ffmpeg.ErrDecoding)
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.True(t, result.InputKept)
+
+	data, err := os.ReadFile(result.OutputPath)
+	require.NoError(t, err)
+	assert.Equal(t, inputData, string(data))
+
+	failures, _, _ := processor.stats.Totals()
+	assert.Equal(t, 1, failures)
+}
+
+func Test_Processor_quality(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		score   string
+		status  Status
+		retries uint
+		output  string
+	}{
+		"accepted": {
+			score:  "0.990000",
+			status: StatusConverted,
+			output: "tiny",
+		},
+		"rejected after retry": {
+			score:   "0.500000",
+			status:  StatusRejected,
+			retries: 1,
+			output:  "large png image data",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			ffmpegPath := writeFakeFFMPEG(t, rootDir)
+			qualityFFMPEG := strings.Replace(fakeFFMPEG, "null) exit 0 ;;",
+				`*ssim) echo "[Parsed_ssim_4 @ 0x0] SSIM Y:0.9 (10.0) All:`+
+					testCase.score+` (10.0)"; exit 0 ;;
+		null) exit 0 ;;`, 1)
+			const perms os.FileMode = 0700
+			err := os.WriteFile(ffmpegPath, []byte(qualityFFMPEG), perms)
+			require.NoError(t, err)
+
+			inputDir := filepath.Join(rootDir, "input")
+			err = os.Mkdir(inputDir, perms)
+			require.NoError(t, err)
+			imagePath := filepath.Join(inputDir, "a.png")
+			err = os.WriteFile(imagePath, []byte("large png image data"), perms)
+			require.NoError(t, err)
+
+			retries := uint(1)
+			settings := Settings{
+				InputDirPath:  inputDir,
+				OutputDirPath: filepath.Join(rootDir, "output"),
+				FfmpegPath:    &ffmpegPath,
+				Quality: QualitySettings{
+					Metric:  "ssim",
+					Retries: &retries,
+				},
+			}
+
+			ctx := context.Background()
+			processor, err := New(ctx, settings, nil, nil, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				err := processor.Close()
+				assert.NoError(t, err)
+			})
+
+			result, err := processor.ProcessFile(ctx, imagePath)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.status, result.Status)
+			assert.Equal(t, "ssim", result.QualityMetric)
+			require.NotNil(t, result.QualityScore)
+			assert.Equal(t, testCase.retries, result.QualityRetries)
+
+			data, err := os.ReadFile(result.OutputPath)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.output, string(data))
+		})
+	}
+}
+
+func Test_Processor_alpha(t *testing.T) {
+	t.Parallel()
+
+	encodePaletted := func(t *testing.T, colorPalette color.Palette) []byte {
+		t.Helper()
+		buffer := bytes.NewBuffer(nil)
+		img := image.NewPaletted(image.Rect(0, 0, 16, 16), colorPalette)
+		err := png.Encode(buffer, img)
+		require.NoError(t, err)
+		return buffer.Bytes()
+	}
+
+	testCases := map[string]struct {
+		pixelFormat    string
+		data           func(t *testing.T) []byte
+		outputFileName string
+	}{
+		"alpha channel": {
+			pixelFormat: "rgba",
+			data: func(*testing.T) []byte {
+				return []byte("large png image data")
+			},
+			outputFileName: "logo.png",
+		},
+		"transparent palette": {
+			pixelFormat: "pal8",
+			data: func(t *testing.T) []byte {
+				return encodePaletted(t, color.Palette{
+					color.NRGBA{}, color.NRGBA{R: 0xff, A: 0xff}})
+			},
+			outputFileName: "logo.png",
+		},
+		"opaque palette": {
+			pixelFormat: "pal8",
+			data: func(t *testing.T) []byte {
+				return encodePaletted(t, color.Palette{
+					color.NRGBA{A: 0xff}, color.NRGBA{R: 0xff, A: 0xff}})
+			},
+			outputFileName: "logo.jpg",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			ffmpegPath := writeFakeFFMPEG(t, rootDir)
+			alphaFFProbe := strings.Replace(fakeFFProbe, `"codec_name":"png",`,
+				`"codec_name":"png","pix_fmt":"`+testCase.pixelFormat+`",`, 1)
+			const perms os.FileMode = 0700
+			err := os.WriteFile(filepath.Join(rootDir, "ffprobe"), []byte(alphaFFProbe), perms)
+			require.NoError(t, err)
+
+			inputDir := filepath.Join(rootDir, "input")
+			err = os.Mkdir(inputDir, perms)
+			require.NoError(t, err)
+			imagePath := filepath.Join(inputDir, "logo.png")
+			err = os.WriteFile(imagePath, testCase.data(t), perms)
+			require.NoError(t, err)
+
+			settings := Settings{
+				InputDirPath:  inputDir,
+				OutputDirPath: filepath.Join(rootDir, "output"),
+				FfmpegPath:    &ffmpegPath,
+			}
+
+			ctx := context.Background()
+			processor, err := New(ctx, settings, nil, nil, nil, nil)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				err := processor.Close()
+				assert.NoError(t, err)
+			})
+
+			result, err := processor.ProcessFile(ctx, imagePath)
+			require.NoError(t, err)
+			assert.Equal(t, StatusConverted, result.Status)
+			assert.Equal(t, testCase.outputFileName, filepath.Base(result.OutputPath))
+
+			data, err := os.ReadFile(result.OutputPath)
+			require.NoError(t, err)
+			assert.Equal(t, "tiny", string(data))
+		})
+	}
+}
+
+func Test_Processor_capabilities(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	ffmpegPath := writeFakeFFMPEG(t, rootDir)
+
+	testCases := map[string]struct {
+		image           ImageSettings
+		codec           string
+		outputExtension string
+		errWrapped      error
+		errMessage      string
+	}{
+		"encoder unavailable": {
+			image:      ImageSettings{Codec: "libwebp"},
+			errWrapped: ErrEncoderUnavailable,
+			errMessage: "invalid settings for " + ffmpegPath + ": image settings: " +
+				"ffmpeg is not built with the encoder: libwebp",
+		},
+		"fallback codec": {
+			image:           ImageSettings{Codec: "libwebp", FallbackCodec: "mjpeg"},
+			codec:           "mjpeg",
+			outputExtension: ".jpg",
+		},
+		"muxer unavailable": {
+			image:      ImageSettings{Codec: "mjpeg", OutputExtension: ".avif"},
+			errWrapped: ErrMuxerUnavailable,
+			errMessage: "invalid settings for " + ffmpegPath + ": image settings: " +
+				"ffmpeg is not built with the muxer: avif for output extension .avif",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			outputDir := t.TempDir()
+			settings := Settings{
+				InputDirPath:  rootDir,
+				OutputDirPath: outputDir,
+				FfmpegPath:    &ffmpegPath,
+				Image:         testCase.image,
+			}
+
+			processor, err := New(context.Background(), settings, nil, nil, nil, nil)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+				return
+			}
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				err := processor.Close()
+				assert.NoError(t, err)
+			})
+			assert.Equal(t, testCase.codec, processor.settings.Image.Codec)
+			assert.Equal(t, testCase.outputExtension, processor.settings.Image.OutputExtension)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package tinier
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/report"
+)
+
+// crfStep is the CRF decrease for each quality retry, minCRF is
+// the lowest CRF used, since lower values are lossless for some
+// encoders, and maxCRF is the highest CRF used.
+const crfStep, minCRF, maxCRF = 4, 1, 51
+
+// encodeWithQuality calls encode with the quality value given, which
+// is a CRF or qscale value where lower values give a higher quality.
+// If the quality check is enabled, it then computes the quality score
+// of the output file compared to the input file and, while the score
+// is below the threshold, calls encode again with the quality value
+// lowered by step down to minValue, up to the number of retries set.
+// It returns accepted as false if the score of the last output is still
+// below the threshold.
+func (p *Processor) encodeWithQuality(ctx context.Context, settings config.Settings,
+	inputPath, outputPath string, value, step, minValue uint,
+	encode func(value uint) error, file *report.File) (
+	outcome string, accepted bool, err error) {
+	err = encode(value)
+	if err != nil {
+		return "", false, err
+	} else if !settings.Quality.Enabled() {
+		return "", true, nil
+	}
+
+	metric := settings.Quality.Metric
+	for {
+		score, err := p.ffmpeg.Quality(ctx, inputPath, outputPath, metric)
+		if err != nil {
+			return "", false, fmt.Errorf("checking quality: %w", err)
+		}
+		file.QualityMetric = metric
+		file.QualityScore = &score
+		outcome = qualityString(metric, score, file.QualityRetries)
+
+		if score >= *settings.Quality.Threshold {
+			return outcome, true, nil
+		} else if file.QualityRetries == *settings.Quality.Retries || value <= minValue {
+			return outcome, false, nil
+		}
+
+		value -= min(step, value-minValue)
+		file.QualityRetries++
+		err = encode(value)
+		if err != nil {
+			return "", false, err
+		}
+	}
+}
+
+// imageQuality returns the quality value of the image settings given,
+// where lower values give a higher quality, together with its step and
+// minimum value for quality retries, and a function returning the
+// ffmpeg image options for a quality value. The quality value is the
+// qscale for mjpeg, the CRF for AV1 codecs, 100 minus the quality for
+// lossy WebP and the distance in hundredths for JPEG XL. Lossless
+// encodings such as PNG have a minimum value of zero, so they are
+// never retried.
+func imageQuality(image config.Image) (value, step, minValue uint,
+	options func(value uint) ffmpeg.ImageOptions) {
+	base := ffmpeg.ImageOptions{
+		Codec:            image.Codec,
+		QScale:           image.QScale,
+		CRF:              image.CRF,
+		Quality:          *image.WebPQuality,
+		Lossless:         *image.WebPLossless,
+		Distance:         *image.JXLDistance,
+		Effort:           image.JXLEffort,
+		CompressionLevel: image.PNGCompressionLevel,
+	}
+
+	switch image.Codec {
+	case "mjpeg":
+		const qScaleStep, minQScale = 2, 2
+		return image.QScale, qScaleStep, minQScale, func(value uint) ffmpeg.ImageOptions {
+			base.QScale = value
+			return base
+		}
+	case "png": // lossless
+		return 0, 0, 0, func(uint) ffmpeg.ImageOptions { return base }
+	case "libwebp":
+		if *image.WebPLossless {
+			return 0, 0, 0, func(uint) ffmpeg.ImageOptions { return base }
+		}
+		const maxQuality, qualityStep = 100, 10
+		return maxQuality - *image.WebPQuality, qualityStep, 0, func(value uint) ffmpeg.ImageOptions {
+			base.Quality = maxQuality - value
+			return base
+		}
+	case "libjxl":
+		const hundredths, distanceStep, minDistance = 100, 50, 10
+		value = uint(math.Round(*image.JXLDistance * hundredths))
+		if value == 0 { // lossless
+			return 0, 0, 0, func(uint) ffmpeg.ImageOptions { return base }
+		}
+		return value, distanceStep, min(minDistance, value), func(value uint) ffmpeg.ImageOptions {
+			base.Distance = float64(value) / hundredths
+			return base
+		}
+	default: // libaom-av1 and libsvtav1
+		return image.CRF, crfStep, minCRF, func(value uint) ffmpeg.ImageOptions {
+			base.CRF = value
+			return base
+		}
+	}
+}
+
+// reject uses the input file as output in place of the temporary
+// output file given, or keeps the input file as is in in-place mode,
+// since the output quality is too low.
+func (p *Processor) reject(settings config.Settings, inputPath,
+	tempOutputPath, outputPath string, file *report.File) (outcome string, err error) {
+	outcome, err = p.fallBack(settings, inputPath, tempOutputPath, outputPath, file, nil)
+	if err != nil {
+		return outcome, err
+	}
+	file.Status = report.StatusRejected
+	return outcome, nil
+}
+
+func qualityString(metric string, score float64, retries uint) string {
+	scoreString := fmt.Sprintf("%.4g", score)
+	if score == math.MaxFloat64 {
+		scoreString = "∞"
+	}
+	s := "🔍 " + strings.ToUpper(metric) + " " + scoreString
+	switch retries {
+	case 0:
+	case 1:
+		s += " after 1 retry"
+	default:
+		s += fmt.Sprintf(" after %d retries", retries)
+	}
+	return s + " "
+}
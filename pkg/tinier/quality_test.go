@@ -0,0 +1,100 @@
+package tinier
+
+import (
+	"testing"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_imageQuality(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		image    config.Image
+		value    uint
+		step     uint
+		minValue uint
+		// retryValue is the quality value passed to options,
+		// and retryOptions the options expected for it.
+		retryValue   uint
+		retryOptions ffmpeg.ImageOptions
+	}{
+		"mjpeg": {
+			image:      config.Image{Codec: "mjpeg", QScale: 5},
+			value:      5,
+			step:       2,
+			minValue:   2,
+			retryValue: 3,
+			retryOptions: ffmpeg.ImageOptions{
+				Codec: "mjpeg", QScale: 3, CRF: 35, Quality: 75, Distance: 1, Effort: 7,
+				CompressionLevel: 9,
+			},
+		},
+		"libsvtav1": {
+			image:      config.Image{Codec: "libsvtav1", CRF: 30},
+			value:      30,
+			step:       crfStep,
+			minValue:   minCRF,
+			retryValue: 26,
+			retryOptions: ffmpeg.ImageOptions{
+				Codec: "libsvtav1", QScale: 5, CRF: 26, Quality: 75, Distance: 1, Effort: 7,
+				CompressionLevel: 9,
+			},
+		},
+		"lossy libwebp": {
+			image:      config.Image{Codec: "libwebp", WebPQuality: ptrTo(uint(80))},
+			value:      20,
+			step:       10,
+			retryValue: 10,
+			retryOptions: ffmpeg.ImageOptions{
+				Codec: "libwebp", QScale: 5, CRF: 35, Quality: 90, Distance: 1, Effort: 7,
+				CompressionLevel: 9,
+			},
+		},
+		"lossless libwebp": {
+			image: config.Image{Codec: "libwebp", WebPLossless: ptrTo(true)},
+			retryOptions: ffmpeg.ImageOptions{
+				Codec: "libwebp", QScale: 5, CRF: 35, Quality: 75, Lossless: true,
+				Distance: 1, Effort: 7,
+				CompressionLevel: 9,
+			},
+		},
+		"png": {
+			image: config.Image{Codec: "png", PNGCompressionLevel: 6},
+			retryOptions: ffmpeg.ImageOptions{
+				Codec: "png", QScale: 5, CRF: 35, Quality: 75, Distance: 1, Effort: 7,
+				CompressionLevel: 6,
+			},
+		},
+		"libjxl": {
+			image:      config.Image{Codec: "libjxl", JXLDistance: ptrTo(1.5)},
+			value:      150,
+			step:       50,
+			minValue:   10,
+			retryValue: 100,
+			retryOptions: ffmpeg.ImageOptions{
+				Codec: "libjxl", QScale: 5, CRF: 35, Quality: 75, Distance: 1, Effort: 7,
+				CompressionLevel: 9,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			settings := Settings{Image: testCase.image}
+			settings.SetDefaults()
+
+			value, step, minValue, options := imageQuality(settings.Image)
+
+			assert.Equal(t, testCase.value, value)
+			assert.Equal(t, testCase.step, step)
+			assert.Equal(t, testCase.minValue, minValue)
+			assert.Equal(t, testCase.retryOptions, options(testCase.retryValue))
+		})
+	}
+}
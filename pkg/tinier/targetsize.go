@@ -0,0 +1,128 @@
+package tinier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/ffmpeg"
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/qdm12/tinier/internal/size"
+)
+
+const (
+	// containerOverheadPercent is the percentage of the target size
+	// kept for the container overhead.
+	containerOverheadPercent = 2
+	// defaultAudioBitRate is the bit rate assumed for audio
+	// streams with an unknown bit rate, in bits per second.
+	defaultAudioBitRate = 128000
+	// minVideoBitRate is the lowest video bit rate accepted,
+	// in bits per second.
+	minVideoBitRate = 10000
+)
+
+var (
+	ErrDurationUnknown    = errors.New("duration is unknown")
+	ErrVideoBitRateTooLow = errors.New("video bit rate is too low")
+	ErrTargetSizeExceeded = errors.New("output file exceeds the target size")
+)
+
+// targetVideoBitRate returns the video bit rate in bits per second to
+// reach the target size or target bit rate of the video settings given,
+// given the probed information of the input video. The audio streams
+// are copied as is, so their bit rate is subtracted from the target.
+func targetVideoBitRate(video config.Video, info ffprobe.Info) (
+	bitRate uint64, err error) {
+	duration := info.Format.Duration
+	if duration <= 0 {
+		return 0, ErrDurationUnknown
+	}
+
+	var targetBitRate uint64
+	if *video.TargetSize != "" {
+		targetSize, err := size.Parse(*video.TargetSize)
+		if err != nil {
+			return 0, fmt.Errorf("parsing target size: %w", err)
+		}
+		const bitsPerByte = 8
+		targetBits := float64(targetSize) * bitsPerByte *
+			(100 - containerOverheadPercent) / 100 //nolint:gomnd
+		targetBitRate = uint64(targetBits / duration.Seconds())
+	} else {
+		targetBitRate, err = size.ParseBitRate(*video.TargetBitRate)
+		if err != nil {
+			return 0, fmt.Errorf("parsing target bit rate: %w", err)
+		}
+	}
+
+	var audioBitRate uint64
+	for _, stream := range info.Streams {
+		if stream.Type != ffprobe.StreamTypeAudio {
+			continue
+		}
+		if stream.BitRate == 0 {
+			audioBitRate += defaultAudioBitRate
+		} else {
+			audioBitRate += stream.BitRate
+		}
+	}
+
+	if targetBitRate < audioBitRate+minVideoBitRate {
+		return 0, fmt.Errorf("%w: target of %dbps with %dbps of audio",
+			ErrVideoBitRateTooLow, targetBitRate, audioBitRate)
+	}
+	return targetBitRate - audioBitRate, nil
+}
+
+// encodeTargetSize encodes the input video to the output path with
+// the video bit rate given, using a temporary directory for the pass
+// log files of two pass encoding.
+func (p *Processor) encodeTargetSize(ctx context.Context, settings config.Settings,
+	inputPath, outputPath, videoScale string, bitRate uint64,
+	onProgress func(progress ffmpeg.Progress)) (err error) {
+	tempDir, err := os.MkdirTemp("", "tinier-pass-")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+
+	return p.ffmpeg.TinyVideoBitRate(ctx, inputPath, outputPath, videoScale,
+		settings.Video.Preset, settings.Video.Codec, bitRate,
+		filepath.Join(tempDir, "pass"), onProgress)
+}
+
+// checkTargetSize returns an error if the final output file at the
+// path given is larger than the target size of the video settings
+// given. This can happen with single pass encoders overshooting the
+// bit rate, or if the input file is used as output since the encoded
+// file is larger.
+func checkTargetSize(video config.Video, outputPath string) (err error) {
+	if *video.TargetSize == "" {
+		return nil
+	}
+
+	targetSize, err := size.Parse(*video.TargetSize)
+	if err != nil {
+		return fmt.Errorf("parsing target size: %w", err)
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("getting output file size: %w", err)
+	} else if stat.Size() > targetSize {
+		return fmt.Errorf("%w: %s is larger than %s", ErrTargetSizeExceeded,
+			size.BytesToHuman(stat.Size()), *video.TargetSize)
+	}
+	return nil
+}
+
+func bitRateString(bitRate uint64) string {
+	const bitsPerKilobit = 1000
+	return fmt.Sprintf("🎯 %dkbps ", bitRate/bitsPerKilobit)
+}
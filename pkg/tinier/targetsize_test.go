@@ -0,0 +1,125 @@
+package tinier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/ffprobe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptrTo[T any](value T) *T { return &value }
+
+func Test_targetVideoBitRate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		video      config.Video
+		info       ffprobe.Info
+		bitRate    uint64
+		errWrapped error
+		errMessage string
+	}{
+		"target size": {
+			video: config.Video{TargetSize: ptrTo("10MB"), TargetBitRate: ptrTo("")},
+			info: ffprobe.Info{
+				Format: ffprobe.Format{Duration: 100 * time.Second},
+				Streams: []ffprobe.Stream{
+					{Type: ffprobe.StreamTypeVideo, BitRate: 5000000},
+					{Type: ffprobe.StreamTypeAudio, BitRate: 96000},
+				},
+			},
+			// 10MiB * 8 * 0.98 / 100s - 96kbps
+			bitRate: 726083,
+		},
+		"target bit rate with unknown audio bit rate": {
+			video: config.Video{TargetSize: ptrTo(""), TargetBitRate: ptrTo("2M")},
+			info: ffprobe.Info{
+				Format: ffprobe.Format{Duration: time.Minute},
+				Streams: []ffprobe.Stream{
+					{Type: ffprobe.StreamTypeAudio},
+					{Type: ffprobe.StreamTypeAudio, BitRate: 64000},
+				},
+			},
+			bitRate: 1808000,
+		},
+		"unknown duration": {
+			video:      config.Video{TargetSize: ptrTo(""), TargetBitRate: ptrTo("2M")},
+			errWrapped: ErrDurationUnknown,
+			errMessage: "duration is unknown",
+		},
+		"audio bit rate above target": {
+			video: config.Video{TargetSize: ptrTo(""), TargetBitRate: ptrTo("100k")},
+			info: ffprobe.Info{
+				Format:  ffprobe.Format{Duration: time.Minute},
+				Streams: []ffprobe.Stream{{Type: ffprobe.StreamTypeAudio}},
+			},
+			errWrapped: ErrVideoBitRateTooLow,
+			errMessage: "video bit rate is too low: target of 100000bps with 128000bps of audio",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bitRate, err := targetVideoBitRate(testCase.video, testCase.info)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+			}
+			assert.Equal(t, testCase.bitRate, bitRate)
+		})
+	}
+}
+
+func Test_checkTargetSize(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		targetSize string
+		fileSize   int
+		errWrapped error
+		errMessage string
+	}{
+		"no target size": {
+			fileSize: 4096,
+		},
+		"below target size": {
+			targetSize: "4KB",
+			fileSize:   4096,
+		},
+		"above target size": {
+			targetSize: "4KB",
+			fileSize:   4097,
+			errWrapped: ErrTargetSizeExceeded,
+			errMessage: "output file exceeds the target size: 4097B is larger than 4KB",
+		},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			outputPath := filepath.Join(t.TempDir(), "video.mp4")
+			const perms os.FileMode = 0600
+			err := os.WriteFile(outputPath, make([]byte, testCase.fileSize), perms)
+			require.NoError(t, err)
+			video := config.Video{TargetSize: ptrTo(testCase.targetSize)}
+
+			err = checkTargetSize(video, outputPath)
+
+			assert.ErrorIs(t, err, testCase.errWrapped)
+			if testCase.errWrapped != nil {
+				assert.EqualError(t, err, testCase.errMessage)
+			}
+		})
+	}
+}
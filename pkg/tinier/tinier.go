@@ -0,0 +1,85 @@
+// Package tinier converts images, audio files and videos to smaller
+// files using ffmpeg, and copies other files as is, so it can be
+// embedded in other Go programs.
+package tinier
+
+import (
+	"io"
+
+	"github.com/qdm12/tinier/internal/config"
+	"github.com/qdm12/tinier/internal/events"
+	"github.com/qdm12/tinier/internal/models"
+	"github.com/qdm12/tinier/internal/report"
+)
+
+type (
+	// Settings are the settings of a processor.
+	Settings = config.Settings
+	// VideoSettings are the settings for video files.
+	VideoSettings = config.Video
+	// ImageSettings are the settings for image files.
+	ImageSettings = config.Image
+	// AudioSettings are the settings for audio files.
+	AudioSettings = config.Audio
+	// QualitySettings are the settings of the quality check
+	// of converted images and videos.
+	QualitySettings = config.Quality
+	// LogSettings are the logging settings.
+	LogSettings = config.Log
+	// Profile is a named set of video, image and audio settings.
+	Profile = config.Profile
+)
+
+// MediaType is the media type of a file, which determines
+// how it is processed.
+type MediaType = models.MediaType
+
+const (
+	MediaTypeImage = models.MediaTypeImage
+	MediaTypeAudio = models.MediaTypeAudio
+	MediaTypeVideo = models.MediaTypeVideo
+	MediaTypeOther = models.MediaTypeOther
+)
+
+// Result is the result of processing a single file.
+type Result = report.File
+
+// Status is the status of a processed file.
+type Status = report.Status
+
+const (
+	StatusConverted       = report.StatusConverted
+	StatusCopied          = report.StatusCopied
+	StatusAlreadyTiny     = report.StatusAlreadyTiny
+	StatusSkippedExisting = report.StatusSkippedExisting
+	StatusSkippedDone     = report.StatusSkippedDone
+	StatusRejected        = report.StatusRejected
+	StatusFailed          = report.StatusFailed
+)
+
+type (
+	// Event is an event of a run, sent to the event sink.
+	Event = events.Event
+	// EventType is the type of an event.
+	EventType = events.Type
+	// EventProgress is the progress of a video conversion.
+	EventProgress = events.Progress
+	// EventStats are the statistics of a run.
+	EventStats = events.Stats
+)
+
+const (
+	EventRunStarted   = events.TypeRunStarted
+	EventFileStarted  = events.TypeFileStarted
+	EventFileProgress = events.TypeFileProgress
+	EventFileFinished = events.TypeFileFinished
+	EventFileSkipped  = events.TypeFileSkipped
+	EventFileFailed   = events.TypeFileFailed
+	EventRunFinished  = events.TypeRunFinished
+)
+
+// NewNDJSONSink returns an event sink writing each event
+// as JSON on a single line to the writer given.
+func NewNDJSONSink(w io.Writer) EventSink {
+	return events.NewJSONWriter(w)
+}